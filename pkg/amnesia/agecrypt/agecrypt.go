@@ -0,0 +1,233 @@
+// Package agecrypt parses age recipients and identities like the age command
+// does, including plugin and SSH ones, and encrypts and decrypts with them.
+package agecrypt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"filippo.io/age/plugin"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"golang.org/x/crypto/ssh"
+)
+
+// TerminalUI is the UI for plugins and SSH key passphrases. Inputs are read
+// from the terminal directly and messages are written to stderr, so neither
+// mixes with stdin or stdout.
+func TerminalUI() *plugin.ClientUI {
+	return plugin.NewTerminalUI(
+		func(format string, v ...any) {
+			fmt.Fprintf(os.Stderr, "age: "+format+"\n", v...)
+		},
+		func(format string, v ...any) {
+			fmt.Fprintf(os.Stderr, "age: warning: "+format+"\n", v...)
+		},
+	)
+}
+
+// ParseRecipient parses a recipient as accepted by age -r
+func ParseRecipient(s string, ui *plugin.ClientUI) (age.Recipient, error) {
+	switch {
+	case strings.HasPrefix(s, "age1pq1"):
+		return age.ParseHybridRecipient(s)
+	case strings.HasPrefix(s, "age1") && strings.Count(s, "1") > 1:
+		return plugin.NewRecipient(s, ui)
+	case strings.HasPrefix(s, "age1"):
+		return age.ParseX25519Recipient(s)
+	case strings.HasPrefix(s, "ssh-"):
+		return agessh.ParseRecipient(s)
+	default:
+		return nil, fmt.Errorf("unknown recipient type: %q", s)
+	}
+}
+
+// ParseRecipients parses a recipients file as accepted by age -R, one
+// recipient per line
+func ParseRecipients(data []byte, ui *plugin.ClientUI) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+
+	n := 0
+	for line := range strings.Lines(string(data)) {
+		n++
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		recipient, err := ParseRecipient(line, ui)
+		if err != nil {
+			return nil, fmt.Errorf("error at line %d: %w", n, err)
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients found")
+	}
+
+	return recipients, nil
+}
+
+func parseIdentity(s string, ui *plugin.ClientUI) (age.Identity, error) {
+	switch {
+	case strings.HasPrefix(s, "AGE-PLUGIN-"):
+		return plugin.NewIdentity(s, ui)
+	case strings.HasPrefix(s, "AGE-SECRET-KEY-PQ-1"):
+		return age.ParseHybridIdentity(s)
+	case strings.HasPrefix(s, "AGE-SECRET-KEY-1"):
+		return age.ParseX25519Identity(s)
+	default:
+		return nil, fmt.Errorf("unknown identity type")
+	}
+}
+
+// ParseIdentities parses an identity file as accepted by age -i, either age
+// identities one per line or an SSH private key. Passphrases of encrypted SSH
+// keys are asked for through ui when they're used. The name of the file is
+// used in prompts and to find the public key of an old format SSH key.
+func ParseIdentities(name string, data []byte, ui *plugin.ClientUI) ([]age.Identity, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		return parseSSHIdentity(name, data, ui)
+	}
+
+	var identities []age.Identity
+
+	n := 0
+	for line := range strings.Lines(string(data)) {
+		n++
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		identity, err := parseIdentity(line, ui)
+		if err != nil {
+			return nil, fmt.Errorf("error at line %d: %w", n, err)
+		}
+
+		identities = append(identities, identity)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identities found")
+	}
+
+	return identities, nil
+}
+
+func parseSSHIdentity(name string, data []byte, ui *plugin.ClientUI) ([]age.Identity, error) {
+	identity, err := agessh.ParseIdentity(data)
+
+	missing, ok := err.(*ssh.PassphraseMissingError)
+	if !ok {
+		if err != nil {
+			return nil, fmt.Errorf("malformed SSH identity: %w", err)
+		}
+		return []age.Identity{identity}, nil
+	}
+
+	public := missing.PublicKey
+	if public == nil {
+		if public, err = readPublicKey(name + ".pub"); err != nil {
+			return nil, err
+		}
+	}
+
+	passphrase := func() ([]byte, error) {
+		if ui == nil || ui.RequestValue == nil {
+			return nil, fmt.Errorf("can't ask for the passphrase of %s", name)
+		}
+
+		value, err := ui.RequestValue("ssh", fmt.Sprintf("Enter passphrase for %q:", name), true)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(value), nil
+	}
+
+	// The key is decrypted when it's first used, after the caller may have
+	// wiped data
+	encrypted, err := agessh.NewEncryptedSSHIdentity(public, bytes.Clone(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return []age.Identity{encrypted}, nil
+}
+
+func readPublicKey(path string) (ssh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted SSH key needs its public key: %w", err)
+	}
+
+	public, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	return public, nil
+}
+
+// Encrypt encrypts plaintext to the recipients, PEM encoded if armored is set
+func Encrypt(plaintext []byte, recipients []age.Recipient, armored bool) ([]byte, error) {
+	var buf bytes.Buffer
+
+	dst := io.WriteCloser(nopCloser{&buf})
+	if armored {
+		dst = armor.NewWriter(&buf)
+	}
+
+	w, err := age.Encrypt(dst, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := dst.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// Decrypt decrypts an age file, which may be PEM encoded
+func Decrypt(data []byte, identities ...age.Identity) ([]byte, error) {
+	src := io.Reader(bytes.NewReader(data))
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(src)
+	}
+
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		secmem.Wipe(plaintext)
+		return nil, err
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,196 @@
+package agecrypt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"filippo.io/age/plugin"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+var testData = []byte("zed > vim")
+
+func TestParseRecipient(t *testing.T) {
+	x25519, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	hybrid, err := age.GenerateHybridIdentity()
+	require.NoError(t, err)
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshPublic, err := ssh.NewPublicKey(public)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		recipient string
+		want      any
+	}{
+		{"X25519", x25519.Recipient().String(), &age.X25519Recipient{}},
+		{"Hybrid", hybrid.Recipient().String(), &age.HybridRecipient{}},
+		{"SSH", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic))), &agessh.Ed25519Recipient{}},
+		{"Plugin", plugin.EncodeRecipient("amnesia", []byte("data")), &plugin.Recipient{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipient, err := ParseRecipient(tt.recipient, TerminalUI())
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, recipient)
+		})
+	}
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := ParseRecipient("pgp-key", TerminalUI())
+		assert.ErrorContains(t, err, "unknown recipient type")
+	})
+}
+
+func TestParseRecipients(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	file := "# comment\n\n" + identity.Recipient().String() + "\n" + plugin.EncodeRecipient("amnesia", []byte("data")) + "\n"
+
+	recipients, err := ParseRecipients([]byte(file), TerminalUI())
+	require.NoError(t, err)
+	assert.Len(t, recipients, 2)
+
+	_, err = ParseRecipients([]byte("# comment\n"), TerminalUI())
+	assert.ErrorContains(t, err, "no recipients found")
+
+	_, err = ParseRecipients([]byte("\nage1bad\n"), TerminalUI())
+	assert.ErrorContains(t, err, "error at line 2")
+}
+
+func TestParseIdentities(t *testing.T) {
+	t.Run("Age", func(t *testing.T) {
+		x25519, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+
+		hybrid, err := age.GenerateHybridIdentity()
+		require.NoError(t, err)
+
+		file := "# created: today\n" + x25519.String() + "\n" + hybrid.String() + "\n" + plugin.EncodeIdentity("amnesia", []byte("data")) + "\n"
+
+		identities, err := ParseIdentities("identity.txt", []byte(file), TerminalUI())
+		require.NoError(t, err)
+		require.Len(t, identities, 3)
+		assert.IsType(t, &age.X25519Identity{}, identities[0])
+		assert.IsType(t, &age.HybridIdentity{}, identities[1])
+		assert.IsType(t, &plugin.Identity{}, identities[2])
+	})
+
+	t.Run("SSH", func(t *testing.T) {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		block, err := ssh.MarshalPrivateKey(private, "")
+		require.NoError(t, err)
+
+		identities, err := ParseIdentities("id_ed25519", pem.EncodeToMemory(block), TerminalUI())
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+
+		sshPublic, err := ssh.NewPublicKey(public)
+		require.NoError(t, err)
+		recipient, err := ParseRecipient(string(ssh.MarshalAuthorizedKey(sshPublic)), TerminalUI())
+		require.NoError(t, err)
+
+		encrypted, err := Encrypt(testData, []age.Recipient{recipient}, false)
+		require.NoError(t, err)
+
+		decrypted, err := Decrypt(encrypted, identities...)
+		require.NoError(t, err)
+		assert.Equal(t, testData, decrypted)
+	})
+
+	t.Run("EncryptedSSH", func(t *testing.T) {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		block, err := ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte("hunter2"))
+		require.NoError(t, err)
+
+		// The passphrase isn't asked for until the identity is used
+		identities, err := ParseIdentities("id_ed25519", pem.EncodeToMemory(block), TerminalUI())
+		require.NoError(t, err)
+		assert.Len(t, identities, 1)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := ParseIdentities("identity.txt", []byte("AGE-SECRET-KEY-2\n"), TerminalUI())
+		assert.ErrorContains(t, err, "error at line 1")
+	})
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	for _, armored := range []bool{false, true} {
+		encrypted, err := Encrypt(testData, []age.Recipient{identity.Recipient()}, armored)
+		require.NoError(t, err)
+		assert.Equal(t, armored, strings.HasPrefix(string(encrypted), armor.Header))
+
+		decrypted, err := Decrypt(encrypted, identity)
+		require.NoError(t, err)
+		assert.Equal(t, testData, decrypted)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	encrypted, err := Encrypt(testData, []age.Recipient{identity.Recipient()}, false)
+	require.NoError(t, err)
+
+	_, err = Decrypt(encrypted, other)
+	assert.Error(t, err)
+}
+
+// TestSealRoundTrip covers unseal --to-age followed by seal --from-age
+func TestSealRoundTrip(t *testing.T) {
+	q := amnesia.NewQuestions()
+	q.Set(0, amnesia.Question{Question: "What's your favourite animal?", Answer: []byte("cat")})
+	q.Set(1, amnesia.Question{Question: "What's your favourite food?", Answer: []byte("pizza")})
+
+	a := amnesia.NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	sealed, err := amnesia.Seal(t.Context(), testData, q, 2)
+	require.NoError(t, err)
+
+	for _, armored := range []bool{false, true} {
+		unsealed, err := amnesia.Unseal(t.Context(), sealed, a)
+		require.NoError(t, err)
+
+		encrypted, err := Encrypt(unsealed.Bytes(), []age.Recipient{identity.Recipient()}, armored)
+		unsealed.Destroy()
+		require.NoError(t, err)
+
+		decrypted, err := Decrypt(encrypted, identity)
+		require.NoError(t, err)
+
+		resealed, err := amnesia.Seal(t.Context(), decrypted, q, 2)
+		require.NoError(t, err)
+
+		unsealed, err = amnesia.Unseal(t.Context(), resealed, a)
+		require.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+		unsealed.Destroy()
+	}
+}
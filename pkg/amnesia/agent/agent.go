@@ -0,0 +1,203 @@
+// Package agent caches DEKs between commands, like ssh-agent does for keys, so
+// a sealed file only has to be unlocked once per session. DEKs are held in
+// locked memory, keyed by the sealed file's fingerprint, and forgotten after
+// an idle timeout.
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// SocketEnv is the environment variable holding the path of the agent socket
+const SocketEnv = "AMNESIA_AGENT_SOCK"
+
+const DefaultIdleTimeout = 15 * time.Minute
+
+var errPeer = errors.New("connection refused from another user")
+
+type entry struct {
+	key  *secmem.Buffer
+	used time.Time
+}
+
+// Agent holds DEKs for clients run by the same user
+type Agent struct {
+	mu   sync.Mutex
+	keys map[string]*entry
+	idle time.Duration
+	now  func() time.Time
+}
+
+// New creates an agent that forgets DEKs unused for longer than idle
+func New(idle time.Duration) *Agent {
+	return &Agent{
+		keys: make(map[string]*entry),
+		idle: idle,
+		now:  time.Now,
+	}
+}
+
+// Close forgets every DEK
+func (a *Agent) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clear()
+}
+
+// Len returns the number of DEKs held
+func (a *Agent) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return len(a.keys)
+}
+
+// clear forgets every DEK. a.mu must be held.
+func (a *Agent) clear() {
+	for fingerprint, e := range a.keys {
+		e.key.Destroy()
+		delete(a.keys, fingerprint)
+	}
+}
+
+// expire forgets DEKs that have been idle for too long. a.mu must be held.
+func (a *Agent) expire() {
+	now := a.now()
+
+	for fingerprint, e := range a.keys {
+		if now.Sub(e.used) > a.idle {
+			e.key.Destroy()
+			delete(a.keys, fingerprint)
+		}
+	}
+}
+
+// Serve serves clients on the listener until ctx is done, then closes the
+// listener and forgets every DEK. Connections from other users are refused.
+func (a *Agent) Serve(ctx context.Context, listener *net.UnixListener) error {
+	// Check peer credentials are supported before accepting anything
+	if err := checkPeerSupported(); err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	defer a.Close()
+
+	go func() {
+		ticker := time.NewTicker(max(a.idle/4, time.Second))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.mu.Lock()
+				a.expire()
+				a.mu.Unlock()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			a.handle(conn)
+		}()
+	}
+}
+
+// handle serves a single request
+func (a *Agent) handle(conn *net.UnixConn) {
+	if uid, err := peerUID(conn); err != nil || uid != os.Getuid() {
+		respond(conn, statusError, []byte(errPeer.Error()))
+		return
+	}
+
+	op, err := readByte(conn)
+	if err != nil {
+		return
+	}
+
+	var fingerprint string
+	if op != opClear {
+		if fingerprint, err = readString(conn); err != nil {
+			return
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.expire()
+
+	switch op {
+	case opGet:
+		e, ok := a.keys[fingerprint]
+		if !ok {
+			respond(conn, statusMissing, nil)
+			return
+		}
+
+		e.used = a.now()
+		respond(conn, statusOK, e.key.Bytes())
+	case opPut:
+		key, err := readField(conn)
+		if err != nil {
+			return
+		}
+
+		if e, ok := a.keys[fingerprint]; ok {
+			e.key.Destroy()
+		}
+		a.keys[fingerprint] = &entry{key: key, used: a.now()}
+
+		respond(conn, statusOK, nil)
+	case opForget:
+		if e, ok := a.keys[fingerprint]; ok {
+			e.key.Destroy()
+			delete(a.keys, fingerprint)
+		}
+
+		respond(conn, statusOK, nil)
+	case opClear:
+		a.clear()
+		respond(conn, statusOK, nil)
+	default:
+		respond(conn, statusError, []byte("unknown request"))
+	}
+}
+
+func respond(conn net.Conn, status byte, data []byte) {
+	if _, err := conn.Write([]byte{status}); err != nil {
+		return
+	}
+
+	writeField(conn, data)
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAgent(t *testing.T, a *Agent) *Client {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error)
+	go func() { served <- a.Serve(ctx, listener) }()
+
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-served)
+	})
+
+	return NewClient(path)
+}
+
+func TestAgent(t *testing.T) {
+	a := New(time.Minute)
+	client := testAgent(t, a)
+
+	dek := bytes.Repeat([]byte{1}, 32)
+
+	key, err := client.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+
+	assert.NoError(t, client.Put("a", dek))
+	assert.NoError(t, client.Put("b", bytes.Repeat([]byte{2}, 32)))
+	assert.Equal(t, 2, a.Len())
+
+	key, err = client.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, dek, key.Bytes())
+	key.Destroy()
+
+	t.Run("Forget", func(t *testing.T) {
+		assert.NoError(t, client.Forget("b"))
+
+		key, err := client.Get("b")
+		assert.NoError(t, err)
+		assert.Nil(t, key)
+		assert.Equal(t, 1, a.Len())
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		assert.NoError(t, client.Clear())
+		assert.Equal(t, 0, a.Len())
+	})
+}
+
+func TestIdleTimeout(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		now = time.Now()
+	)
+
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	a := New(time.Minute)
+	a.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	client := testAgent(t, a)
+
+	assert.NoError(t, client.Put("a", bytes.Repeat([]byte{1}, 32)))
+
+	// Using a DEK keeps it alive
+	advance(50 * time.Second)
+	key, err := client.Get("a")
+	assert.NoError(t, err)
+	assert.NotNil(t, key)
+	key.Destroy()
+
+	advance(50 * time.Second)
+	key, err = client.Get("a")
+	assert.NoError(t, err)
+	assert.NotNil(t, key)
+	key.Destroy()
+
+	advance(2 * time.Minute)
+	key, err = client.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+}
+
+func TestNoAgent(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+
+	_, err := client.Get("a")
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+const clientTimeout = 5 * time.Second
+
+// Client talks to an agent over its socket. Each request is made on a new
+// connection.
+type Client struct {
+	path string
+}
+
+func NewClient(path string) *Client {
+	return &Client{path: path}
+}
+
+// FromEnv returns a client for the agent in AMNESIA_AGENT_SOCK, or nil if it
+// isn't set
+func FromEnv() *Client {
+	path := os.Getenv(SocketEnv)
+	if path == "" {
+		return nil
+	}
+
+	return NewClient(path)
+}
+
+// Get returns the DEK for the fingerprint, or nil if the agent doesn't have
+// it. The caller must destroy the returned buffer.
+func (c *Client) Get(fingerprint string) (*secmem.Buffer, error) {
+	return c.call(opGet, fingerprint, nil)
+}
+
+// Put gives the agent the DEK for the fingerprint
+func (c *Client) Put(fingerprint string, key []byte) error {
+	_, err := c.call(opPut, fingerprint, key)
+	return err
+}
+
+// Forget removes the DEK for the fingerprint from the agent
+func (c *Client) Forget(fingerprint string) error {
+	_, err := c.call(opForget, fingerprint, nil)
+	return err
+}
+
+// Clear removes every DEK from the agent
+func (c *Client) Clear() error {
+	_, err := c.call(opClear, "", nil)
+	return err
+}
+
+func (c *Client) call(op byte, fingerprint string, key []byte) (*secmem.Buffer, error) {
+	conn, err := net.DialTimeout("unix", c.path, clientTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to agent: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(clientTimeout))
+
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("agent socket isn't a unix socket")
+	}
+
+	// DEKs are only handed to an agent run by the same user
+	uid, err := peerUID(unixConn)
+	if err != nil {
+		return nil, fmt.Errorf("error checking agent credentials: %w", err)
+	}
+	if uid != os.Getuid() {
+		return nil, fmt.Errorf("agent is run by another user")
+	}
+
+	if _, err := conn.Write([]byte{op}); err != nil {
+		return nil, err
+	}
+	if op != opClear {
+		if err := writeField(conn, []byte(fingerprint)); err != nil {
+			return nil, err
+		}
+	}
+	if op == opPut {
+		if err := writeField(conn, key); err != nil {
+			return nil, err
+		}
+	}
+
+	status, err := readByte(conn)
+	if err != nil {
+		return nil, fmt.Errorf("error reading agent response: %w", err)
+	}
+
+	data, err := readField(conn)
+	if err != nil {
+		return nil, fmt.Errorf("error reading agent response: %w", err)
+	}
+
+	switch status {
+	case statusOK:
+		if op == opGet {
+			return data, nil
+		}
+		data.Destroy()
+		return nil, nil
+	case statusMissing:
+		data.Destroy()
+		return nil, nil
+	default:
+		defer data.Destroy()
+		return nil, fmt.Errorf("agent: %s", data.Bytes())
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd
+
+package agent
+
+import (
+	"errors"
+	"net"
+)
+
+var errPeerUnsupported = errors.New("the agent isn't supported on this platform, as peer credentials can't be checked")
+
+func checkPeerSupported() error {
+	return errPeerUnsupported
+}
+
+func peerUID(*net.UnixConn) (int, error) {
+	return -1, errPeerUnsupported
+}
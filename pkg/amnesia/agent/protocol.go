@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// Requests and responses are a type byte followed by length-prefixed fields,
+// so keys can be read straight into locked memory
+const (
+	opGet byte = iota + 1
+	opPut
+	opForget
+	opClear
+)
+
+const (
+	statusOK byte = iota
+	statusMissing
+	statusError
+)
+
+const maxField = 1024
+
+func writeField(w io.Writer, data []byte) error {
+	if len(data) > maxField {
+		return fmt.Errorf("field too long")
+	}
+
+	if _, err := w.Write(binary.BigEndian.AppendUint16(nil, uint16(len(data)))); err != nil {
+		return err
+	}
+
+	_, err := w.Write(data)
+	return err
+}
+
+// readField reads a field into locked memory
+func readField(r io.Reader) (*secmem.Buffer, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+
+	size := int(binary.BigEndian.Uint16(length[:]))
+	if size > maxField {
+		return nil, fmt.Errorf("field too long")
+	}
+
+	buf := secmem.New(size)
+	if _, err := io.ReadFull(r, buf.Bytes()); err != nil {
+		buf.Destroy()
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// readString reads a field that isn't sensitive
+func readString(r io.Reader) (string, error) {
+	buf, err := readField(r)
+	if err != nil {
+		return "", err
+	}
+	defer buf.Destroy()
+
+	return string(buf.Bytes()), nil
+}
+
+func readByte(r io.Reader) (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
@@ -2,16 +2,25 @@ package ageplugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 
 	"filippo.io/age"
 	"filippo.io/age/plugin"
 	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/agent"
 	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 )
 
 const pluginName = "amnesia"
 
+// keyfileEnv can be set to the path of a keyfile to avoid being prompted for it
+const keyfileEnv = "AMNESIA_KEYFILE"
+
 func Main() int {
 	plugin, err := plugin.New(pluginName)
 	if err != nil {
@@ -19,6 +28,18 @@ func Main() int {
 		return 1
 	}
 
+	plugin.HandleRecipient(func(data []byte) (age.Recipient, error) {
+		template, err := parseTemplate(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return templateRecipient{
+			template: template,
+			plugin:   plugin,
+		}, nil
+	})
+
 	plugin.HandleIdentity(func(data []byte) (age.Identity, error) {
 		return identityPlugin{
 			data:   data,
@@ -29,44 +50,305 @@ func Main() int {
 	return plugin.Main()
 }
 
+// prompter is how the plugin talks to the user through age. It's implemented
+// by *plugin.Plugin.
+type prompter interface {
+	DisplayMessage(message string) error
+	RequestValue(prompt string, secret bool) (string, error)
+	Confirm(prompt, yes, no string) (bool, error)
+}
+
 type identityPlugin struct {
-	plugin *plugin.Plugin
+	plugin prompter
 	data   []byte
 }
 
 func (i identityPlugin) unwrap(stanzas []*age.Stanza) ([]byte, error) {
-	sealedSecret, err := amnesia.Decode(i.data)
+	// Files encrypted to a question template hold their own sealed file key,
+	// and can be decrypted with age -j amnesia, without identity data
+	if slices.ContainsFunc(stanzas, func(s *age.Stanza) bool { return s.Type == stanzaType }) {
+		return i.unwrapStanzas(stanzas)
+	}
+	if len(i.data) == 0 {
+		return nil, age.ErrIncorrectIdentity
+	}
+
+	sealed, err := resolveIdentity(i.data)
+	if err != nil {
+		return nil, err
+	}
+
+	unsealed, err := i.unseal(sealed)
+	if err != nil {
+		return nil, err
+	}
+	defer unsealed.Destroy()
+
+	keyring, err := ParseKeyring(unsealed.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// One round of questions unseals the whole keyring
+	return i.unwrapKeyring(keyring, stanzas)
+}
+
+// unwrapKeyring tries each identity in the keyring in turn. An identity that
+// fails for another reason, like its plugin not being installed, doesn't stop
+// the rest being tried, but its error is returned if none of them match.
+func (i identityPlugin) unwrapKeyring(keyring Keyring, stanzas []*age.Stanza) ([]byte, error) {
+	var firstErr error
+
+	for _, s := range keyring {
+		var fileKey []byte
+
+		identity, err := i.parseIdentity(s)
+		if err == nil {
+			fileKey, err = identity.Unwrap(stanzas)
+		}
+
+		if err == nil {
+			return fileKey, nil
+		}
+		if firstErr == nil && !errors.Is(err, age.ErrIncorrectIdentity) {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return nil, age.ErrIncorrectIdentity
+}
+
+// parseIdentity parses the unsealed identity. Imported plugin identities are
+// unwrapped by running their plugin, which talks to the user through age.
+func (i identityPlugin) parseIdentity(s string) (age.Identity, error) {
+	if _, err := recipientOf(s); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(s, "AGE-PLUGIN-") {
+		return parseNativeIdentity(s)
+	}
+
+	return plugin.NewIdentity(s, &plugin.ClientUI{
+		DisplayMessage: func(name, message string) error {
+			return i.plugin.DisplayMessage(fmt.Sprintf("%s: %s", name, message))
+		},
+		RequestValue: func(name, prompt string, secret bool) (string, error) {
+			return i.plugin.RequestValue(prompt, secret)
+		},
+		Confirm: func(name, prompt, yes, no string) (bool, error) {
+			return i.plugin.Confirm(prompt, yes, no)
+		},
+	})
+}
+
+// unseal unseals a sealed secret
+func (i identityPlugin) unseal(sealed []byte) (*secmem.Buffer, error) {
+	sealedSecret, err := amnesia.Decode(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := i.decryptKey(sealedSecret)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	return amnesia.UnsealWithKey(sealed, key.Bytes())
+}
+
+// decryptKey recovers the DEK, from the agent in AMNESIA_AGENT_SOCK if it's
+// running and remembers it, otherwise by asking the questions
+func (i identityPlugin) decryptKey(sealedSecret *amnesia.SealedSecret) (*secmem.Buffer, error) {
+	client := agent.FromEnv()
+	if client == nil {
+		return i.answerQuestions(sealedSecret)
+	}
+
+	fingerprint := sealedSecret.Fingerprint()
+
+	key, err := client.Get(fingerprint)
+	if err != nil {
+		i.plugin.DisplayMessage(fmt.Sprintf("amnesia: %v", err))
+	}
+	if key != nil {
+		if amnesia.VerifyKey(sealedSecret, key.Bytes()) == nil {
+			return key, nil
+		}
+		key.Destroy()
+	}
+
+	key, err = i.answerQuestions(sealedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Put(fingerprint, key.Bytes()); err != nil {
+		i.plugin.DisplayMessage(fmt.Sprintf("amnesia: %v", err))
+	}
+
+	return key, nil
+}
+
+// answerQuestions recovers the DEK by asking every question
+func (i identityPlugin) answerQuestions(sealedSecret *amnesia.SealedSecret) (*secmem.Buffer, error) {
+	set, opts, err := questionSet(sealedSecret)
 	if err != nil {
 		return nil, err
 	}
 
+	keyfile, err := i.keyfile(set)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(keyfile)
+
+	answers, err := i.requestAnswers(set)
+	if err != nil {
+		return nil, err
+	}
+	defer answers.Wipe()
+
+	opts = append(opts, amnesia.WithKeyfile(keyfile))
+
+	key, err := amnesia.DecryptKey(context.Background(), sealedSecret, answers, opts...)
+	if errors.Is(err, amnesia.ErrIncorrectKeyfile) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error unsealing key (incorrect or too few answers?)")
+	}
+
+	return key, nil
+}
+
+// requestAnswers asks questions until threshold of them are answered. Before
+// each question that can be skipped the user is asked whether they know the
+// answer, and a blank or "-" answer also skips it, for clients that can't
+// confirm.
+func (i identityPlugin) requestAnswers(set *amnesia.ShareSet) (amnesia.Answers, error) {
+	var questions []amnesia.Share
+	for _, share := range set.Shares {
+		// Trustee and exported shares can't be entered through the plugin
+		if !share.IsTrustee() && !share.Exported {
+			questions = append(questions, share)
+		}
+	}
+
+	// Older sealed files don't record the threshold, so every question is
+	// asked
+	threshold := set.Threshold
+	if threshold == 0 {
+		threshold = len(questions)
+	}
+	if len(questions) < max(threshold, amnesia.MinQuestions) {
+		return nil, fmt.Errorf("only %d of %d required questions can be answered through age", len(questions), threshold)
+	}
+
 	answers := amnesia.NewAnswers()
+	canConfirm := true
+
+	for idx, share := range questions {
+		if len(answers) == threshold {
+			break
+		}
+
+		progress := fmt.Sprintf("%d/%d answers", len(answers), threshold)
+		if set.Threshold == 0 {
+			progress = fmt.Sprintf("%d answers", len(answers))
+		}
 
-	for _, share := range sealedSecret.Shares {
-		s := fmt.Sprintf("amnesia: Enter answer to question\n%s:", share.Question)
-		answer, err := i.plugin.RequestValue(s, false)
+		// Questions can only be skipped if enough are left to reach the
+		// threshold without them
+		skippable := len(questions)-idx > threshold-len(answers) || set.Threshold == 0
+
+		if skippable && canConfirm {
+			prompt := fmt.Sprintf("amnesia: Do you know the answer to this question? (%s)\n%s", progress, share.Question)
+
+			known, err := i.plugin.Confirm(prompt, "Answer", "Skip")
+			if err != nil {
+				canConfirm = false
+			} else if !known {
+				continue
+			}
+		}
+
+		prompt := fmt.Sprintf("amnesia: Enter answer to question (%s)\n%s:", progress, share.Question)
+		if skippable && !canConfirm {
+			prompt = fmt.Sprintf("amnesia: Enter answer to question, or - to skip (%s)\n%s:", progress, share.Question)
+		}
+
+		answer, err := i.plugin.RequestValue(prompt, true)
 		if err != nil {
+			answers.Wipe()
 			return nil, err
 		}
+		if answer == "" || (skippable && answer == "-") {
+			continue
+		}
 
-		answers.Set(share.ID, answer)
+		answers.Set(share.ID, []byte(answer))
 	}
 
-	unsealed, err := amnesia.Unseal(i.data, answers)
-	if err != nil {
-		return nil, fmt.Errorf("error unsealing key (incorrect or too few answers?)")
+	if len(answers) < max(threshold, amnesia.MinQuestions) && set.Threshold != 0 {
+		answers.Wipe()
+		return nil, fmt.Errorf("too few answers, %d of %d required", len(answers), threshold)
 	}
 
-	identity, err := age.ParseX25519Identity(string(unsealed))
+	return answers, nil
+}
+
+// questionSet returns the share set to ask questions from. The plugin can only
+// ask questions, so for files with slots the first questions slot is used.
+func questionSet(sealedSecret *amnesia.SealedSecret) (*amnesia.ShareSet, []amnesia.Option, error) {
+	if sealedSecret.Version != amnesia.FormatV3 {
+		return &sealedSecret.ShareSet, nil, nil
+	}
+
+	for _, slot := range sealedSecret.Slots {
+		if slot.Type == amnesia.SlotQuestions {
+			return &slot.ShareSet, []amnesia.Option{amnesia.WithSlot(slot.ID)}, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("identity has no questions slot")
+}
+
+// keyfile reads the keyfile if the share set requires one, from the path in
+// AMNESIA_KEYFILE or otherwise prompting for it
+func (i identityPlugin) keyfile(set *amnesia.ShareSet) ([]byte, error) {
+	if !set.RequiresKeyfile() {
+		return nil, nil
+	}
+
+	path := os.Getenv(keyfileEnv)
+	if path == "" {
+		var err error
+
+		path, err = i.plugin.RequestValue("amnesia: Enter path to keyfile:", false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyfile, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error reading keyfile: %w", err)
 	}
 
-	return identity.Unwrap(stanzas)
+	return keyfile, nil
 }
 
 func (i identityPlugin) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
 	identityKey, err := i.unwrap(stanzas)
+	if errors.Is(err, age.ErrIncorrectIdentity) {
+		return nil, err
+	}
 	if err != nil {
 		i.plugin.DisplayMessage(err.Error())
 		return nil, age.ErrIncorrectIdentity
@@ -76,16 +358,23 @@ func (i identityPlugin) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
 }
 
 type Identity struct {
-	ageIdentity  *age.X25519Identity
+	recipient    string
 	sealedSecret []byte
 }
 
 func (i Identity) Identity() string {
-	return plugin.EncodeIdentity(pluginName, i.sealedSecret)
+	return EmbeddedIdentity(i.sealedSecret)
 }
 
+// Sealed returns the sealed identity, to be stored for a Reference to it
+func (i Identity) Sealed() []byte {
+	return i.sealedSecret
+}
+
+// Recipient returns the recipient of the newest identity in the keyring, which
+// is empty for imported plugin identities as only their plugin knows it
 func (i Identity) Recipient() string {
-	return i.ageIdentity.Recipient().String()
+	return i.recipient
 }
 
 func GenerateIdentity(ctx context.Context, opts ...interactive.Option) (Identity, error) {
@@ -94,13 +383,16 @@ func GenerateIdentity(ctx context.Context, opts ...interactive.Option) (Identity
 		return Identity{}, err
 	}
 
-	sealed, err := interactive.Seal(ctx, []byte(identity.String()), opts...)
+	return ImportIdentity(ctx, Keyring{identity.String()}, opts...)
+}
+
+// GenerateHybridIdentity generates a post-quantum ML-KEM-768 + X25519 hybrid
+// identity, whose recipients are much longer than X25519 ones
+func GenerateHybridIdentity(ctx context.Context, opts ...interactive.Option) (Identity, error) {
+	identity, err := age.GenerateHybridIdentity()
 	if err != nil {
 		return Identity{}, err
 	}
 
-	return Identity{
-		sealedSecret: sealed,
-		ageIdentity:  identity,
-	}, nil
+	return ImportIdentity(ctx, Keyring{identity.String()}, opts...)
 }
@@ -0,0 +1,210 @@
+package ageplugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKDFParams = amnesia.KDFParams{
+	Time:    1,
+	Memory:  64,
+	Threads: 1,
+}
+
+// fakePrompter answers prompts from a script. Confirm fails if confirms is
+// nil, like clients that can't confirm.
+type fakePrompter struct {
+	values   []string
+	confirms []bool
+
+	prompts  []string
+	secret   []bool
+	messages []string
+}
+
+func (f *fakePrompter) DisplayMessage(message string) error {
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+func (f *fakePrompter) RequestValue(prompt string, secret bool) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	f.secret = append(f.secret, secret)
+
+	if len(f.values) == 0 {
+		return "", errors.New("no more values")
+	}
+
+	value := f.values[0]
+	f.values = f.values[1:]
+
+	return value, nil
+}
+
+func (f *fakePrompter) Confirm(prompt, yes, no string) (bool, error) {
+	if f.confirms == nil {
+		return false, errors.New("confirm not supported")
+	}
+
+	f.prompts = append(f.prompts, prompt)
+
+	if len(f.confirms) == 0 {
+		return false, errors.New("no more confirms")
+	}
+
+	confirm := f.confirms[0]
+	f.confirms = f.confirms[1:]
+
+	return confirm, nil
+}
+
+func testShareSet(threshold int, questions ...string) *amnesia.ShareSet {
+	set := &amnesia.ShareSet{Threshold: threshold}
+	for id, question := range questions {
+		set.Shares = append(set.Shares, amnesia.Share{ID: id, Question: question})
+	}
+
+	return set
+}
+
+func TestRequestAnswers(t *testing.T) {
+	t.Run("Skip", func(t *testing.T) {
+		p := &fakePrompter{
+			confirms: []bool{false},
+			values:   []string{"pizza", "green"},
+		}
+		set := testShareSet(2, "animal", "food", "colour")
+
+		answers, err := identityPlugin{plugin: p}.requestAnswers(set)
+		require.NoError(t, err)
+		assert.Equal(t, amnesia.Answers{1: []byte("pizza"), 2: []byte("green")}, answers)
+
+		// Only the first question can be skipped, as the threshold can't be
+		// reached without the others
+		require.Len(t, p.prompts, 3)
+		assert.Contains(t, p.prompts[0], "Do you know the answer")
+		assert.Contains(t, p.prompts[0], "animal")
+		assert.Contains(t, p.prompts[1], "food")
+		assert.Contains(t, p.prompts[2], "colour")
+
+		// Answers aren't echoed, as when encrypting to a template
+		assert.Equal(t, []bool{true, true}, p.secret)
+	})
+
+	t.Run("CantConfirm", func(t *testing.T) {
+		p := &fakePrompter{
+			values: []string{"-", "pizza", "green"},
+		}
+		set := testShareSet(2, "animal", "food", "colour")
+
+		answers, err := identityPlugin{plugin: p}.requestAnswers(set)
+		require.NoError(t, err)
+		assert.Equal(t, amnesia.Answers{1: []byte("pizza"), 2: []byte("green")}, answers)
+
+		require.Len(t, p.prompts, 3)
+		assert.Contains(t, p.prompts[0], "or - to skip")
+		assert.NotContains(t, p.prompts[1], "or - to skip")
+		assert.NotContains(t, p.prompts[2], "or - to skip")
+	})
+
+	t.Run("DashNotSkippable", func(t *testing.T) {
+		p := &fakePrompter{
+			values: []string{"-", "pizza"},
+		}
+		set := testShareSet(2, "animal", "food")
+
+		answers, err := identityPlugin{plugin: p}.requestAnswers(set)
+		require.NoError(t, err)
+		assert.Equal(t, amnesia.Answers{0: []byte("-"), 1: []byte("pizza")}, answers)
+	})
+
+	t.Run("TooFewAnswers", func(t *testing.T) {
+		p := &fakePrompter{
+			confirms: []bool{},
+			values:   []string{"", "pizza"},
+		}
+		set := testShareSet(2, "animal", "food")
+
+		_, err := identityPlugin{plugin: p}.requestAnswers(set)
+		assert.ErrorContains(t, err, "too few answers, 1 of 2 required")
+	})
+
+	t.Run("NoThreshold", func(t *testing.T) {
+		// Older files don't record the threshold, so every question can be
+		// skipped and no number of answers is required up front
+		p := &fakePrompter{
+			confirms: []bool{true, false},
+			values:   []string{"cat"},
+		}
+		set := testShareSet(0, "animal", "food")
+
+		answers, err := identityPlugin{plugin: p}.requestAnswers(set)
+		require.NoError(t, err)
+		assert.Equal(t, amnesia.Answers{0: []byte("cat")}, answers)
+
+		require.Len(t, p.prompts, 3)
+		assert.Contains(t, p.prompts[0], "(0 answers)")
+		assert.Contains(t, p.prompts[2], "(1 answers)")
+	})
+
+	t.Run("TrusteeAndExported", func(t *testing.T) {
+		p := &fakePrompter{
+			values: []string{"cat", "green"},
+		}
+		set := &amnesia.ShareSet{
+			Threshold: 2,
+			Shares: []amnesia.Share{
+				{ID: 0, Question: "animal"},
+				{ID: 1, Recipient: "age1trustee"},
+				{ID: 2, Question: "food", Exported: true},
+				{ID: 3, Question: "colour"},
+			},
+		}
+
+		answers, err := identityPlugin{plugin: p}.requestAnswers(set)
+		require.NoError(t, err)
+		assert.Equal(t, amnesia.Answers{0: []byte("cat"), 3: []byte("green")}, answers)
+
+		for _, prompt := range p.prompts {
+			assert.NotContains(t, prompt, "food")
+		}
+
+		set.Threshold = 3
+
+		_, err = identityPlugin{plugin: &fakePrompter{}}.requestAnswers(set)
+		assert.ErrorContains(t, err, "only 2 of 3 required questions")
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		p := &fakePrompter{
+			values: []string{"cat"},
+		}
+		set := testShareSet(2, "animal", "food")
+
+		_, err := identityPlugin{plugin: p}.requestAnswers(set)
+		assert.ErrorContains(t, err, "no more values")
+	})
+}
+
+func testQuestions() amnesia.Questions {
+	q := amnesia.NewQuestions()
+	q.Set(0, amnesia.Question{Question: "What's your favourite animal?", Answer: []byte("cat")})
+	q.Set(1, amnesia.Question{Question: "What's your favourite food?", Answer: []byte("pizza")})
+	return q
+}
+
+func testAnswers() amnesia.Answers {
+	a := amnesia.NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+	return a
+}
+
+func testSeal(ctx context.Context, secret []byte) ([]byte, error) {
+	return amnesia.Seal(ctx, secret, testQuestions(), 2, amnesia.WithKDFParams(testKDFParams))
+}
@@ -0,0 +1,99 @@
+package ageplugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// recipientOf checks an identity can be sealed and returns its recipient.
+// Plugin identities can only be turned into recipients by their plugin, so
+// theirs is empty.
+func recipientOf(identity string) (string, error) {
+	if !strings.HasPrefix(identity, "AGE-PLUGIN-") {
+		parsed, err := parseNativeIdentity(identity)
+		if err != nil {
+			return "", err
+		}
+
+		switch parsed := parsed.(type) {
+		case *age.X25519Identity:
+			return parsed.Recipient().String(), nil
+		case *age.HybridIdentity:
+			return parsed.Recipient().String(), nil
+		}
+	}
+
+	name, _, err := plugin.ParseIdentity(identity)
+	if err != nil {
+		return "", err
+	}
+	if name == pluginName {
+		return "", fmt.Errorf("amnesia identities can't be sealed inside each other")
+	}
+
+	return "", nil
+}
+
+func parseNativeIdentity(s string) (age.Identity, error) {
+	identities, err := age.ParseIdentities(strings.NewReader(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) != 1 {
+		return nil, fmt.Errorf("expected one identity")
+	}
+
+	return identities[0], nil
+}
+
+// ImportIdentity seals existing X25519, hybrid or plugin identities, so files
+// already encrypted to their recipients can be decrypted with questions
+func ImportIdentity(ctx context.Context, keyring Keyring, opts ...interactive.Option) (Identity, error) {
+	return importIdentity(keyring, func(secret []byte) ([]byte, error) {
+		return interactive.Seal(ctx, secret, opts...)
+	})
+}
+
+// importIdentity seals the keyring with seal
+func importIdentity(keyring Keyring, seal func(secret []byte) ([]byte, error)) (Identity, error) {
+	if len(keyring) == 0 {
+		return Identity{}, fmt.Errorf("no identities to seal")
+	}
+
+	secret := keyring.Encode()
+	defer secmem.Wipe(secret)
+
+	sealed, err := seal(secret)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return Identity{
+		sealedSecret: sealed,
+		recipient:    keyring.recipient(),
+	}, nil
+}
+
+// RekeyIdentity unseals a sealed identity with unlockOpts and seals it again
+// with new questions asked with sealOpts. The identities inside don't change,
+// and so neither do their recipients.
+func RekeyIdentity(ctx context.Context, sealed []byte, unlockOpts, sealOpts []interactive.Option) (Identity, error) {
+	return rekeyIdentity(ctx, sealed, unlockOpts, func(secret []byte) ([]byte, error) {
+		return interactive.Seal(ctx, secret, sealOpts...)
+	})
+}
+
+func rekeyIdentity(ctx context.Context, sealed []byte, unlockOpts []interactive.Option, seal func(secret []byte) ([]byte, error)) (Identity, error) {
+	keyring, err := UnsealKeyring(ctx, sealed, unlockOpts...)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return importIdentity(keyring, seal)
+}
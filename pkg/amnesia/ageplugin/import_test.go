@@ -0,0 +1,75 @@
+package ageplugin
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/agent"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testUnlock returns options which unlock the sealed identity without
+// prompting, through an agent that remembers its DEK
+func testUnlock(t *testing.T, sealed []byte) []interactive.Option {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error)
+	go func() { served <- agent.New(time.Minute).Serve(ctx, listener) }()
+
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-served)
+	})
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	require.NoError(t, err)
+
+	key, err := amnesia.DecryptKey(t.Context(), sealedSecret, testAnswers())
+	require.NoError(t, err)
+	defer key.Destroy()
+
+	client := agent.NewClient(path)
+	require.NoError(t, client.Put(sealedSecret.Fingerprint(), key.Bytes()))
+
+	return []interactive.Option{interactive.WithAgent(client)}
+}
+
+func testIdentity(t *testing.T, keyring Keyring) Identity {
+	identity, err := importIdentity(keyring, func(secret []byte) ([]byte, error) {
+		return testSeal(t.Context(), secret)
+	})
+	require.NoError(t, err)
+
+	return identity
+}
+
+func TestRekeyIdentity(t *testing.T) {
+	x25519, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	identity := testIdentity(t, Keyring{x25519.String()})
+	assert.Equal(t, x25519.Recipient().String(), identity.Recipient())
+
+	rekeyed, err := rekeyIdentity(t.Context(), identity.Sealed(), testUnlock(t, identity.Sealed()), func(secret []byte) ([]byte, error) {
+		return testSeal(t.Context(), secret)
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, identity.Sealed(), rekeyed.Sealed())
+	assert.Equal(t, identity.Recipient(), rekeyed.Recipient())
+
+	keyring, err := UnsealKeyring(t.Context(), rekeyed.Sealed(), testUnlock(t, rekeyed.Sealed())...)
+	require.NoError(t, err)
+	assert.Equal(t, Keyring{x25519.String()}, keyring)
+}
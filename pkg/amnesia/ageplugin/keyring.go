@@ -0,0 +1,166 @@
+package ageplugin
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"filippo.io/age/plugin"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// Keyring is the list of identities sealed in an amnesia identity, oldest
+// first, so files encrypted to rotated identities can still be decrypted. It's
+// sealed in the age identity file format, so identities sealed on their own are
+// keyrings of one.
+type Keyring []string
+
+// KeyringEntry describes an identity in a keyring without revealing it
+type KeyringEntry struct {
+	// Type is X25519, hybrid, or the name of the identity's plugin
+	Type string
+	// Recipient is empty for plugin identities
+	Recipient string
+}
+
+// ParseKeyring parses identities one per line, ignoring blank lines and
+// comments
+func ParseKeyring(data []byte) (Keyring, error) {
+	var keyring Keyring
+
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := keyring.add(line); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("no identities found")
+	}
+
+	return keyring, nil
+}
+
+func (k *Keyring) add(identity string) error {
+	if _, err := recipientOf(identity); err != nil {
+		return fmt.Errorf("invalid identity: %w", err)
+	}
+	if slices.Contains(*k, identity) {
+		return fmt.Errorf("identity is already in the keyring")
+	}
+
+	*k = append(*k, identity)
+
+	return nil
+}
+
+// Add returns the keyring with the identity added as the newest
+func (k Keyring) Add(identity string) (Keyring, error) {
+	k = slices.Clone(k)
+	if err := k.add(identity); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Remove returns the keyring without the identity at index
+func (k Keyring) Remove(index int) (Keyring, error) {
+	if index < 0 || index >= len(k) {
+		return nil, fmt.Errorf("no identity %d in the keyring", index+1)
+	}
+	if len(k) == 1 {
+		return nil, fmt.Errorf("can't remove the only identity in the keyring")
+	}
+
+	return slices.Delete(slices.Clone(k), index, index+1), nil
+}
+
+// Entries describes the identities in the keyring
+func (k Keyring) Entries() []KeyringEntry {
+	entries := make([]KeyringEntry, len(k))
+
+	for idx, identity := range k {
+		entries[idx].Recipient, _ = recipientOf(identity)
+
+		switch {
+		case strings.HasPrefix(identity, "AGE-SECRET-KEY-PQ-1"):
+			entries[idx].Type = "hybrid"
+		case strings.HasPrefix(identity, "AGE-SECRET-KEY-1"):
+			entries[idx].Type = "X25519"
+		default:
+			entries[idx].Type, _, _ = plugin.ParseIdentity(identity)
+		}
+	}
+
+	return entries
+}
+
+// Encode returns the keyring in the age identity file format. A keyring of one
+// encodes to just its identity, as identities were sealed before keyrings.
+func (k Keyring) Encode() []byte {
+	return []byte(strings.Join(k, "\n"))
+}
+
+// recipient returns the recipient of the newest identity
+func (k Keyring) recipient() string {
+	recipient, _ := recipientOf(k[len(k)-1])
+	return recipient
+}
+
+// UnsealKeyring unseals the keyring in a sealed identity
+func UnsealKeyring(ctx context.Context, sealed []byte, opts ...interactive.Option) (Keyring, error) {
+	unsealed, err := interactive.Unseal(ctx, sealed, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal identity: %w", err)
+	}
+	defer unsealed.Destroy()
+
+	return ParseKeyring(unsealed.Bytes())
+}
+
+// EditKeyring unseals the keyring in a sealed identity, changes it with edit
+// and seals it again with the same questions
+func EditKeyring(ctx context.Context, sealed []byte, edit func(Keyring) (Keyring, error), opts ...interactive.Option) (Identity, error) {
+	key, err := interactive.DecryptKey(ctx, sealed, opts...)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to unseal identity: %w", err)
+	}
+	defer key.Destroy()
+
+	unsealed, err := amnesia.UnsealWithKey(sealed, key.Bytes())
+	if err != nil {
+		return Identity{}, err
+	}
+	defer unsealed.Destroy()
+
+	keyring, err := ParseKeyring(unsealed.Bytes())
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if keyring, err = edit(keyring); err != nil {
+		return Identity{}, err
+	}
+
+	secret := keyring.Encode()
+	defer secmem.Wipe(secret)
+
+	resealed, err := amnesia.ResealWithKey(sealed, secret, key.Bytes())
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return Identity{
+		sealedSecret: resealed,
+		recipient:    keyring.recipient(),
+	}, nil
+}
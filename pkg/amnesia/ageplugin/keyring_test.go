@@ -0,0 +1,163 @@
+package ageplugin
+
+import (
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	x25519, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	hybrid, err := age.GenerateHybridIdentity()
+	require.NoError(t, err)
+
+	yubikey := plugin.EncodeIdentity("yubikey", []byte("data"))
+
+	t.Run("Legacy", func(t *testing.T) {
+		// Identities were sealed on their own before keyrings
+		keyring, err := ParseKeyring([]byte(x25519.String()))
+		require.NoError(t, err)
+		assert.Equal(t, Keyring{x25519.String()}, keyring)
+		assert.Equal(t, []byte(x25519.String()), keyring.Encode())
+		assert.Equal(t, x25519.Recipient().String(), keyring.recipient())
+	})
+
+	t.Run("Parse", func(t *testing.T) {
+		data := "# created: today\n" + x25519.String() + "\n\n" + hybrid.String() + "\n" + yubikey + "\n"
+
+		keyring, err := ParseKeyring([]byte(data))
+		require.NoError(t, err)
+		assert.Equal(t, Keyring{x25519.String(), hybrid.String(), yubikey}, keyring)
+
+		parsed, err := ParseKeyring(keyring.Encode())
+		require.NoError(t, err)
+		assert.Equal(t, keyring, parsed)
+
+		assert.Equal(t, []KeyringEntry{
+			{Type: "X25519", Recipient: x25519.Recipient().String()},
+			{Type: "hybrid", Recipient: hybrid.Recipient().String()},
+			{Type: "yubikey"},
+		}, keyring.Entries())
+
+		// Plugin identities' recipients are only known to their plugin
+		assert.Empty(t, keyring.recipient())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseKeyring([]byte("# nothing\n"))
+		assert.ErrorContains(t, err, "no identities found")
+
+		_, err = ParseKeyring([]byte("AGE-SECRET-KEY-1BAD\n"))
+		assert.ErrorContains(t, err, "invalid identity")
+
+		_, err = ParseKeyring([]byte(x25519.String() + "\n" + x25519.String()))
+		assert.ErrorContains(t, err, "already in the keyring")
+
+		_, err = ParseKeyring([]byte(EmbeddedIdentity([]byte("{}"))))
+		assert.ErrorContains(t, err, "can't be sealed inside each other")
+	})
+
+	t.Run("AddRemove", func(t *testing.T) {
+		keyring := Keyring{x25519.String()}
+
+		added, err := keyring.Add(hybrid.String())
+		require.NoError(t, err)
+		assert.Equal(t, Keyring{x25519.String(), hybrid.String()}, added)
+		assert.Equal(t, Keyring{x25519.String()}, keyring)
+		assert.Equal(t, hybrid.Recipient().String(), added.recipient())
+
+		_, err = added.Add(x25519.String())
+		assert.ErrorContains(t, err, "already in the keyring")
+
+		removed, err := added.Remove(0)
+		require.NoError(t, err)
+		assert.Equal(t, Keyring{hybrid.String()}, removed)
+		assert.Equal(t, Keyring{x25519.String(), hybrid.String()}, added)
+
+		_, err = added.Remove(2)
+		assert.ErrorContains(t, err, "no identity 3")
+
+		_, err = removed.Remove(0)
+		assert.ErrorContains(t, err, "only identity")
+	})
+}
+
+func TestEditKeyring(t *testing.T) {
+	old, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	added, err := age.GenerateHybridIdentity()
+	require.NoError(t, err)
+
+	identity := testIdentity(t, Keyring{old.String()})
+
+	t.Run("Add", func(t *testing.T) {
+		edited, err := EditKeyring(t.Context(), identity.Sealed(), func(keyring Keyring) (Keyring, error) {
+			return keyring.Add(added.String())
+		}, testUnlock(t, identity.Sealed())...)
+		require.NoError(t, err)
+
+		// The newest identity's recipient is the one to encrypt to
+		assert.Equal(t, added.Recipient().String(), edited.Recipient())
+
+		// The questions stay the same, so the same answers unseal it
+		keyring, err := UnsealKeyring(t.Context(), edited.Sealed(), testUnlock(t, edited.Sealed())...)
+		require.NoError(t, err)
+		assert.Equal(t, Keyring{old.String(), added.String()}, keyring)
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		edited, err := EditKeyring(t.Context(), identity.Sealed(), func(keyring Keyring) (Keyring, error) {
+			return keyring, nil
+		}, testUnlock(t, identity.Sealed())...)
+		require.NoError(t, err)
+		assert.Equal(t, old.Recipient().String(), edited.Recipient())
+	})
+
+	t.Run("EditError", func(t *testing.T) {
+		_, err := EditKeyring(t.Context(), identity.Sealed(), func(keyring Keyring) (Keyring, error) {
+			return keyring.Remove(0)
+		}, testUnlock(t, identity.Sealed())...)
+		assert.ErrorContains(t, err, "only identity")
+	})
+}
+
+func TestUnwrapKeyring(t *testing.T) {
+	x25519, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	fileKey := make([]byte, 16)
+	stanzas, err := x25519.Recipient().Wrap(fileKey)
+	require.NoError(t, err)
+
+	// The plugin isn't installed, so its identity can't unwrap anything
+	t.Setenv("PATH", t.TempDir())
+	missing := plugin.EncodeIdentity("missing", []byte("data"))
+
+	i := identityPlugin{plugin: &fakePrompter{}}
+
+	unwrapped, err := i.unwrapKeyring(Keyring{missing, other.String(), x25519.String()}, stanzas)
+	require.NoError(t, err)
+	assert.Equal(t, fileKey, unwrapped)
+
+	_, err = i.unwrapKeyring(Keyring{other.String()}, stanzas)
+	assert.ErrorIs(t, err, age.ErrIncorrectIdentity)
+
+	var notFound *plugin.NotFoundError
+	_, err = i.unwrapKeyring(Keyring{other.String(), missing}, stanzas)
+	assert.ErrorAs(t, err, &notFound)
+
+	_, err = i.unwrapKeyring(Keyring{"AGE-SECRET-KEY-1BAD", x25519.String()}, stanzas)
+	assert.NoError(t, err)
+
+	_, err = i.unwrapKeyring(Keyring{"AGE-SECRET-KEY-1BAD", other.String()}, stanzas)
+	assert.ErrorContains(t, err, "invalid")
+}
@@ -0,0 +1,184 @@
+package ageplugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// stanzaType is the type of stanzas holding a file key sealed directly with
+// questions
+const stanzaType = "amnesia"
+
+// Template is a set of questions without answers. It can be used as an age
+// recipient, in which case the answers are given when encrypting and the file
+// key is sealed with them into the file itself.
+type Template struct {
+	Threshold int      `json:"threshold"`
+	Questions []string `json:"questions"`
+}
+
+func (t Template) Validate() error {
+	if len(t.Questions) < amnesia.MinQuestions {
+		return amnesia.ErrTooFewQuestions
+	}
+	if len(t.Questions) > amnesia.MaxQuestions {
+		return amnesia.ErrTooManyQuestions
+	}
+	if t.Threshold < amnesia.MinQuestions || t.Threshold > len(t.Questions) {
+		return fmt.Errorf("threshold must be between %d and the number of questions", amnesia.MinQuestions)
+	}
+
+	for i, question := range t.Questions {
+		if question == "" {
+			return fmt.Errorf("question %d is empty", i+1)
+		}
+		if slices.Contains(t.Questions[:i], question) {
+			return fmt.Errorf("question %q is repeated", question)
+		}
+	}
+
+	return nil
+}
+
+// Recipient encodes the template as an age1amnesia1... recipient
+func (t Template) Recipient() (string, error) {
+	if err := t.Validate(); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+
+	return plugin.EncodeRecipient(pluginName, data), nil
+}
+
+func parseTemplate(data []byte) (Template, error) {
+	var t Template
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Template{}, fmt.Errorf("invalid amnesia recipient: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return Template{}, fmt.Errorf("invalid amnesia recipient: %w", err)
+	}
+
+	return t, nil
+}
+
+// TemplateFromSealed takes the questions and threshold of an existing sealed
+// file, from its first questions slot if it has slots. Trustee shares are left
+// out, so the threshold must be reachable with questions alone.
+func TemplateFromSealed(sealed []byte) (Template, error) {
+	sealedSecret, err := amnesia.Decode(sealed)
+	if err != nil {
+		return Template{}, err
+	}
+
+	set, _, err := questionSet(sealedSecret)
+	if err != nil {
+		return Template{}, err
+	}
+
+	t := Template{Threshold: set.Threshold}
+	for _, share := range set.Shares {
+		if !share.IsTrustee() {
+			t.Questions = append(t.Questions, share.Question)
+		}
+	}
+
+	if t.Threshold == 0 {
+		return Template{}, fmt.Errorf("sealed file doesn't record its threshold")
+	}
+
+	if err := t.Validate(); err != nil {
+		return Template{}, err
+	}
+
+	return t, nil
+}
+
+type templateRecipient struct {
+	plugin   prompter
+	template Template
+}
+
+// Wrap asks for the answers to the template's questions and seals the file key
+// with them
+func (r templateRecipient) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	questions, err := r.requestAnswers()
+	if err != nil {
+		return nil, err
+	}
+	defer questions.Wipe()
+
+	sealed, err := amnesia.Seal(context.Background(), fileKey, questions, r.template.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*age.Stanza{{Type: stanzaType, Body: sealed}}, nil
+}
+
+// requestAnswers asks for the answer to every question twice, as a mistake
+// would leave the file undecryptable
+func (r templateRecipient) requestAnswers() (amnesia.Questions, error) {
+	questions := amnesia.NewQuestions()
+
+	for id, question := range r.template.Questions {
+		for {
+			answer, err := r.plugin.RequestValue(fmt.Sprintf("amnesia: Enter answer to encrypt with\n%s:", question), true)
+			if err != nil {
+				questions.Wipe()
+				return nil, err
+			}
+			if answer == "" {
+				r.plugin.DisplayMessage("amnesia: Answer cannot be empty")
+				continue
+			}
+
+			confirm, err := r.plugin.RequestValue(fmt.Sprintf("amnesia: Enter answer again\n%s:", question), true)
+			if err != nil {
+				questions.Wipe()
+				return nil, err
+			}
+			if answer != confirm {
+				r.plugin.DisplayMessage("amnesia: Answers don't match")
+				continue
+			}
+
+			questions.Set(id, amnesia.Question{Question: question, Answer: []byte(answer)})
+			break
+		}
+	}
+
+	return questions, nil
+}
+
+// unwrapStanzas unseals the file key from the first amnesia stanza the user
+// can answer the questions of
+func (i identityPlugin) unwrapStanzas(stanzas []*age.Stanza) ([]byte, error) {
+	err := age.ErrIncorrectIdentity
+
+	for _, stanza := range stanzas {
+		if stanza.Type != stanzaType {
+			continue
+		}
+
+		var fileKey *secmem.Buffer
+		if fileKey, err = i.unseal(stanza.Body); err == nil {
+			defer fileKey.Destroy()
+			return bytes.Clone(fileKey.Bytes()), nil
+		}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,96 @@
+package ageplugin
+
+import (
+	"strings"
+	"testing"
+
+	"filippo.io/age/plugin"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	template := Template{
+		Threshold: 2,
+		Questions: []string{"animal", "food", "colour"},
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		recipient, err := template.Recipient()
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(recipient, "age1amnesia1"))
+
+		name, data, err := plugin.ParseRecipient(recipient)
+		require.NoError(t, err)
+		assert.Equal(t, pluginName, name)
+
+		parsed, err := parseTemplate(data)
+		require.NoError(t, err)
+		assert.Equal(t, template, parsed)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			template Template
+			err      string
+		}{
+			{"TooFew", Template{Threshold: 1, Questions: []string{"animal"}}, amnesia.ErrTooFewQuestions.Error()},
+			{"ThresholdTooLow", Template{Threshold: 1, Questions: []string{"animal", "food"}}, "threshold must be between"},
+			{"ThresholdTooHigh", Template{Threshold: 3, Questions: []string{"animal", "food"}}, "threshold must be between"},
+			{"Empty", Template{Threshold: 2, Questions: []string{"animal", ""}}, "question 2 is empty"},
+			{"Repeated", Template{Threshold: 2, Questions: []string{"animal", "animal"}}, `question "animal" is repeated`},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.ErrorContains(t, tt.template.Validate(), tt.err)
+
+				_, err := tt.template.Recipient()
+				assert.Error(t, err)
+			})
+		}
+
+		assert.NoError(t, template.Validate())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := parseTemplate([]byte("not json"))
+		assert.ErrorContains(t, err, "invalid amnesia recipient")
+
+		_, err = parseTemplate([]byte(`{"threshold":2,"questions":["animal"]}`))
+		assert.ErrorContains(t, err, "invalid amnesia recipient")
+	})
+}
+
+func TestTemplateFromSealed(t *testing.T) {
+	sealed, err := testSeal(t.Context(), []byte("secret"))
+	require.NoError(t, err)
+
+	template, err := TemplateFromSealed(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, Template{
+		Threshold: 2,
+		Questions: []string{"What's your favourite animal?", "What's your favourite food?"},
+	}, template)
+}
+
+func TestTemplateRecipientRequestAnswers(t *testing.T) {
+	p := &fakePrompter{
+		values: []string{"", "cat", "dog", "cat", "cat", "pizza", "pizza"},
+	}
+	r := templateRecipient{
+		plugin:   p,
+		template: Template{Threshold: 2, Questions: []string{"animal", "food"}},
+	}
+
+	questions, err := r.requestAnswers()
+	require.NoError(t, err)
+	assert.Equal(t, amnesia.Questions{
+		0: {Question: "animal", Answer: []byte("cat")},
+		1: {Question: "food", Answer: []byte("pizza")},
+	}, questions)
+	assert.NotContains(t, p.secret, false)
+	assert.Equal(t, []string{"amnesia: Answer cannot be empty", "amnesia: Answers don't match"}, p.messages)
+}
@@ -0,0 +1,294 @@
+package ageplugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age/plugin"
+	"github.com/cedws/amnesia/pkg/amnesia"
+)
+
+// identityPathEnv lists directories, separated like PATH, to look for sealed
+// identities referred to by hash in. The identity store is always searched.
+const identityPathEnv = "AMNESIA_IDENTITY_PATH"
+
+// Reference points to a sealed identity file instead of embedding it, so the
+// identity stays short and the sealed file can change without invalidating
+// copies of it. Exactly one of Path and SHA256 is set.
+type Reference struct {
+	// Path is the absolute path of the sealed identity
+	Path string `json:"path,omitempty"`
+	// SHA256 is the hash of the sealed identity, looked up in the identity
+	// store and AMNESIA_IDENTITY_PATH
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type referenceData struct {
+	Reference *Reference `json:"reference"`
+}
+
+// PathReference refers to the sealed identity at path
+func PathReference(path string) (Reference, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Reference{}, err
+	}
+
+	return Reference{Path: abs}, nil
+}
+
+// HashReference refers to the sealed identity by its hash. Changing the sealed
+// identity changes its hash, so the reference has to be made again.
+func HashReference(sealed []byte) Reference {
+	sum := sha256.Sum256(sealed)
+	return Reference{SHA256: hex.EncodeToString(sum[:])}
+}
+
+// Identity encodes the reference as an AGE-PLUGIN-AMNESIA-1... identity
+func (r Reference) Identity() (string, error) {
+	data, err := json.Marshal(referenceData{Reference: &r})
+	if err != nil {
+		return "", err
+	}
+
+	return plugin.EncodeIdentity(pluginName, data), nil
+}
+
+// Resolve reads the sealed identity the reference points to
+func (r Reference) Resolve() ([]byte, error) {
+	if r.Path != "" {
+		sealed, err := os.ReadFile(r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading referenced identity: %w", err)
+		}
+
+		return sealed, nil
+	}
+
+	_, sealed, err := r.find()
+	return sealed, err
+}
+
+// find looks for the sealed identity a hash reference refers to, returning its
+// path as well
+func (r Reference) find() (string, []byte, error) {
+	for _, dir := range identityDirs() {
+		path, sealed, err := findByHash(dir, r.SHA256)
+		if err != nil {
+			return "", nil, err
+		}
+		if sealed != nil {
+			return path, sealed, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("no sealed identity with hash %s found, add its directory to %s", r.SHA256, identityPathEnv)
+}
+
+// RemoveStored removes the sealed identity a hash reference refers to once
+// it's been replaced, so it can't be unsealed with the old answers. Only files
+// in the identity store are removed. The path of the sealed identity is
+// returned either way, so others can be pointed out.
+func RemoveStored(r Reference) (path string, removed bool, err error) {
+	if r.SHA256 == "" {
+		return "", false, fmt.Errorf("not a hash reference")
+	}
+
+	path, _, err = r.find()
+	if err != nil {
+		return "", false, err
+	}
+
+	store, err := IdentityStore()
+	if err != nil || filepath.Dir(path) != store {
+		return path, false, nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return path, false, err
+	}
+
+	return path, true, nil
+}
+
+// maxSealedSize is the largest file findByHash reads. Sealed identities are a
+// few kilobytes, so anything much bigger isn't one.
+const maxSealedSize = 1 << 20
+
+// findByHash looks for a file in dir with the hash, returning its path and
+// contents, or nil if there isn't one. The file StoreSealed names after the
+// hash is tried first, then the other JSON files, in case it was renamed.
+func findByHash(dir, hash string) (string, []byte, error) {
+	named := filepath.Join(dir, hash+".json")
+
+	sealed, err := readWithHash(named, hash)
+	if sealed != nil || err != nil {
+		return named, sealed, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.Type().IsRegular() || path == named || filepath.Ext(path) != ".json" {
+			continue
+		}
+
+		sealed, err := readWithHash(path, hash)
+		if sealed != nil || err != nil {
+			return path, sealed, err
+		}
+	}
+
+	return "", nil, nil
+}
+
+// readWithHash reads the file at path if it has the hash, returning nil if it
+// doesn't exist, has another hash or is too big to be a sealed identity
+func readWithHash(path, hash string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > maxSealedSize {
+		return nil, nil
+	}
+
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if HashReference(sealed).SHA256 != hash {
+		return nil, nil
+	}
+
+	return sealed, nil
+}
+
+// IdentityStore is the directory sealed identities are written to by default
+func IdentityStore() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "amnesia", "identities"), nil
+}
+
+func identityDirs() []string {
+	var dirs []string
+
+	if store, err := IdentityStore(); err == nil {
+		dirs = append(dirs, store)
+	}
+	if path := os.Getenv(identityPathEnv); path != "" {
+		dirs = append(dirs, filepath.SplitList(path)...)
+	}
+
+	return dirs
+}
+
+// StoreSealed writes the sealed identity to path, or to the identity store
+// named by its hash if path is empty, and returns the path written. An
+// existing file is only accepted if it has the same contents.
+func StoreSealed(path string, sealed []byte) (string, error) {
+	if path == "" {
+		store, err := IdentityStore()
+		if err != nil {
+			return "", err
+		}
+
+		path = filepath.Join(store, HashReference(sealed).SHA256+".json")
+	}
+
+	if existing, err := os.ReadFile(path); err == nil {
+		if !bytes.Equal(existing, sealed) {
+			return "", fmt.Errorf("%s already exists", path)
+		}
+		return path, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// resolveIdentity returns the sealed identity embedded in or referred to by
+// identity data
+func resolveIdentity(data []byte) ([]byte, error) {
+	var ref referenceData
+	if err := json.Unmarshal(data, &ref); err != nil || ref.Reference == nil {
+		return data, nil
+	}
+
+	return ref.Reference.Resolve()
+}
+
+// IdentityReference returns the reference in an AGE-PLUGIN-AMNESIA-1...
+// identity, or nil if the sealed identity is embedded in it
+func IdentityReference(identity string) (*Reference, error) {
+	name, data, err := plugin.ParseIdentity(strings.TrimSpace(identity))
+	if err != nil {
+		return nil, err
+	}
+	if name != pluginName {
+		return nil, fmt.Errorf("not an amnesia identity")
+	}
+
+	var ref referenceData
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return nil, nil
+	}
+
+	return ref.Reference, nil
+}
+
+// ResolveIdentity returns the sealed identity embedded in or referred to by an
+// AGE-PLUGIN-AMNESIA-1... identity
+func ResolveIdentity(identity string) ([]byte, error) {
+	name, data, err := plugin.ParseIdentity(strings.TrimSpace(identity))
+	if err != nil {
+		return nil, err
+	}
+	if name != pluginName {
+		return nil, fmt.Errorf("not an amnesia identity")
+	}
+
+	sealed, err := resolveIdentity(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := amnesia.Decode(sealed); err != nil {
+		return nil, fmt.Errorf("invalid sealed identity: %w", err)
+	}
+
+	return sealed, nil
+}
+
+// EmbeddedIdentity encodes the sealed identity as an AGE-PLUGIN-AMNESIA-1...
+// identity holding all of it
+func EmbeddedIdentity(sealed []byte) string {
+	return plugin.EncodeIdentity(pluginName, sealed)
+}
@@ -0,0 +1,176 @@
+package ageplugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdentityPath points the identity store and AMNESIA_IDENTITY_PATH at
+// temporary directories, returning the latter
+func testIdentityPath(t *testing.T) string {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	t.Setenv(identityPathEnv, dir)
+
+	return dir
+}
+
+func TestReference(t *testing.T) {
+	sealed, err := testSeal(t.Context(), []byte("secret"))
+	require.NoError(t, err)
+
+	t.Run("Path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "identity.json")
+		require.NoError(t, os.WriteFile(path, sealed, 0600))
+
+		ref, err := PathReference(path)
+		require.NoError(t, err)
+
+		identity, err := ref.Identity()
+		require.NoError(t, err)
+
+		parsed, err := IdentityReference(identity)
+		require.NoError(t, err)
+		assert.Equal(t, &ref, parsed)
+
+		resolved, err := ResolveIdentity(identity)
+		require.NoError(t, err)
+		assert.Equal(t, sealed, resolved)
+	})
+
+	t.Run("Hash", func(t *testing.T) {
+		dir := testIdentityPath(t)
+
+		ref := HashReference(sealed)
+
+		_, err := ref.Resolve()
+		assert.ErrorContains(t, err, "no sealed identity with hash")
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "identity.json"), sealed, 0600))
+
+		resolved, err := ref.Resolve()
+		require.NoError(t, err)
+		assert.Equal(t, sealed, resolved)
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		testIdentityPath(t)
+
+		path, err := StoreSealed("", sealed)
+		require.NoError(t, err)
+		assert.Equal(t, HashReference(sealed).SHA256+".json", filepath.Base(path))
+
+		// Storing it again is fine, but not over something else
+		_, err = StoreSealed("", sealed)
+		assert.NoError(t, err)
+
+		_, err = StoreSealed(path, []byte("{}"))
+		assert.ErrorContains(t, err, "already exists")
+
+		resolved, err := HashReference(sealed).Resolve()
+		require.NoError(t, err)
+		assert.Equal(t, sealed, resolved)
+	})
+
+	t.Run("RemoveStored", func(t *testing.T) {
+		dir := testIdentityPath(t)
+
+		ref := HashReference(sealed)
+
+		// Sealed identities outside the store are only pointed out
+		elsewhere := filepath.Join(dir, "identity.json")
+		require.NoError(t, os.WriteFile(elsewhere, sealed, 0600))
+
+		path, removed, err := RemoveStored(ref)
+		require.NoError(t, err)
+		assert.False(t, removed)
+		assert.Equal(t, elsewhere, path)
+		assert.FileExists(t, elsewhere)
+
+		stored, err := StoreSealed("", sealed)
+		require.NoError(t, err)
+
+		path, removed, err = RemoveStored(ref)
+		require.NoError(t, err)
+		assert.True(t, removed)
+		assert.Equal(t, stored, path)
+		assert.NoFileExists(t, stored)
+
+		_, _, err = RemoveStored(Reference{Path: elsewhere})
+		assert.Error(t, err)
+	})
+
+	t.Run("Embedded", func(t *testing.T) {
+		identity := EmbeddedIdentity(sealed)
+
+		ref, err := IdentityReference(identity)
+		require.NoError(t, err)
+		assert.Nil(t, ref)
+
+		resolved, err := ResolveIdentity(identity)
+		require.NoError(t, err)
+		assert.Equal(t, sealed, resolved)
+	})
+}
+
+func TestFindByHash(t *testing.T) {
+	dir := t.TempDir()
+
+	sealed, err := testSeal(t.Context(), []byte("secret"))
+	require.NoError(t, err)
+
+	hash := HashReference(sealed).SHA256
+
+	_, found, err := findByHash(filepath.Join(dir, "missing"), hash)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "renamed.json"), sealed, 0600))
+
+	path, found, err := findByHash(dir, hash)
+	require.NoError(t, err)
+	assert.Equal(t, sealed, found)
+	assert.Equal(t, filepath.Join(dir, "renamed.json"), path)
+
+	_, found, err = findByHash(dir, HashReference([]byte("other")).SHA256)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	t.Run("Named", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, hash+".json"), sealed, 0600))
+
+		path, found, err := findByHash(dir, hash)
+		require.NoError(t, err)
+		assert.Equal(t, sealed, found)
+		assert.Equal(t, filepath.Join(dir, hash+".json"), path)
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		dir := t.TempDir()
+
+		// Only JSON files are read, and only if small enough to be sealed
+		// identities
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "identity.txt"), sealed, 0600))
+
+		large := append(slices.Clone(sealed), bytes.Repeat([]byte(" "), maxSealedSize)...)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "large.json"), large, 0600))
+
+		_, found, err := findByHash(dir, hash)
+		assert.NoError(t, err)
+		assert.Nil(t, found)
+
+		_, found, err = findByHash(dir, HashReference(large).SHA256)
+		assert.NoError(t, err)
+		assert.Nil(t, found)
+	})
+}
@@ -1,13 +1,11 @@
 package amnesia
 
 import (
-	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 
-	"golang.org/x/crypto/argon2"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 )
 
 const (
@@ -15,6 +13,16 @@ const (
 	MaxQuestions = 255
 )
 
+const (
+	FormatV1 = "1"
+	// FormatV2 records the KDF parameters alongside the shares
+	FormatV2 = "2"
+	// FormatV3 protects the DEK with one or more independent key slots
+	FormatV3 = "3"
+
+	DefaultFormatVersion = FormatV2
+)
+
 var encoding = base64.StdEncoding
 
 var (
@@ -22,6 +30,10 @@ var (
 	ErrTooManyQuestions = fmt.Errorf("too many questions, maximum is %d", MaxQuestions)
 )
 
+var (
+	ErrUnsupportedKDFParams = fmt.Errorf("format version %s only supports the default kdf parameters", FormatV1)
+)
+
 var (
 	ErrTooFewAnswers  = fmt.Errorf("too few answers, minimum is %d", MinQuestions)
 	ErrTooManyAnswers = fmt.Errorf("too many answers, maximum is %d", MaxQuestions)
@@ -29,21 +41,68 @@ var (
 
 type Share struct {
 	ID       int    `json:"id"`
-	Question string `json:"question"`
-	Salt     string `json:"salt"`
-	Share    string `json:"share"`
+	Question string `json:"question,omitempty"`
+	Salt     string `json:"salt,omitempty"`
+	// Recipient is set for trustee shares, which are encrypted to an age
+	// recipient rather than under an answer
+	Recipient string `json:"recipient,omitempty"`
+	// Exported is set when the share has been moved out to a share file
+	Exported bool   `json:"exported,omitempty"`
+	Share    string `json:"share,omitempty"`
+}
+
+// IsTrustee reports whether the share is held by a trustee
+func (s *Share) IsTrustee() bool {
+	return s.Recipient != ""
+}
+
+// ShareSet is a set of question and trustee shares, threshold of which are
+// required to recover the key they protect
+type ShareSet struct {
+	Threshold int            `json:"threshold,omitempty"`
+	KDF       *KDFParams     `json:"kdf,omitempty"`
+	Keyfile   *KeyfileParams `json:"keyfile,omitempty"`
+	Shares    []Share        `json:"shares,omitempty"`
 }
 
 type SealedSecret struct {
-	Version         string  `json:"version"`
-	SealedTimestamp string  `json:"sealed_timestamp"`
-	Shares          []Share `json:"shares"`
-	Encrypted       []byte  `json:"encrypted"`
+	Version         string `json:"version"`
+	SealedTimestamp string `json:"sealed_timestamp"`
+	// SecretFormat is set if the secret is converted before sealing, such as
+	// to the entropy of a BIP-39 mnemonic
+	SecretFormat string `json:"secret_format,omitempty"`
+	// ShareSet protects the DEK directly in version 1 and 2
+	ShareSet
+	// Slots each protect the DEK independently in version 3
+	Slots     []Slot `json:"slots,omitempty"`
+	Encrypted []byte `json:"encrypted"`
+}
+
+// kdfParams returns the KDF parameters the shares were sealed with. Version 1
+// always uses the default parameters.
+func (s *SealedSecret) kdfParams() (KDFParams, error) {
+	if s.Version == FormatV1 {
+		return DefaultKDFParams, nil
+	}
+
+	return validKDFParams(s.KDF)
+}
+
+func validKDFParams(params *KDFParams) (KDFParams, error) {
+	if params == nil {
+		return KDFParams{}, fmt.Errorf("missing kdf parameters")
+	}
+	if err := params.Validate(); err != nil {
+		return KDFParams{}, err
+	}
+
+	return *params, nil
 }
 
 type Question struct {
 	Question string
-	Answer   string
+	// Answer is kept as bytes rather than a string so it can be wiped
+	Answer []byte
 }
 
 type Questions map[int]Question
@@ -66,6 +125,13 @@ func (q Questions) Set(id int, question Question) {
 	q[id] = question
 }
 
+// Wipe zeroes all answers
+func (q Questions) Wipe() {
+	for _, question := range q {
+		secmem.Wipe(question.Answer)
+	}
+}
+
 func (q Questions) Contains(s string) bool {
 	for _, question := range q {
 		if question.Question == s {
@@ -76,7 +142,8 @@ func (q Questions) Contains(s string) bool {
 	return false
 }
 
-type Answers map[int]string
+// Answers are kept as bytes rather than strings so they can be wiped
+type Answers map[int][]byte
 
 func NewAnswers() Answers {
 	return make(Answers)
@@ -92,29 +159,21 @@ func (a Answers) Validate() error {
 	return nil
 }
 
-func (a Answers) Set(id int, answer string) {
+func (a Answers) Set(id int, answer []byte) {
 	a[id] = answer
 }
 
-func kdf(password, salt []byte) []byte {
-	const (
-		time    = uint32(5)
-		memory  = uint32(64 * 1024) // 64MiB (unit is KiB)
-		threads = uint8(4)
-		keyLen  = uint32(32)
-	)
-
-	return argon2.IDKey(password, salt, time, memory, threads, keyLen)
+// Delete removes and wipes an answer
+func (a Answers) Delete(id int) {
+	secmem.Wipe(a[id])
+	delete(a, id)
 }
 
-func random(length int) []byte {
-	salt := make([]byte, length)
-
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		panic(err)
+// Wipe zeroes all answers
+func (a Answers) Wipe() {
+	for _, answer := range a {
+		secmem.Wipe(answer)
 	}
-
-	return salt
 }
 
 func Decode(buf []byte) (*SealedSecret, error) {
@@ -124,9 +183,38 @@ func Decode(buf []byte) (*SealedSecret, error) {
 		return nil, err
 	}
 
+	// KDF parameters are checked up front so a crafted file is rejected
+	// before anything is derived with them
+	if err := sf.validateKDFParams(); err != nil {
+		return nil, err
+	}
+
 	return &sf, nil
 }
 
+func (s *SealedSecret) validateKDFParams() error {
+	sets := []*ShareSet{&s.ShareSet}
+	for i := range s.Slots {
+		sets = append(sets, &s.Slots[i].ShareSet)
+	}
+
+	for _, set := range sets {
+		if set.KDF == nil {
+			continue
+		}
+		if err := set.KDF.Validate(); err != nil {
+			return fmt.Errorf("invalid kdf parameters: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func Encode(sealedSecret *SealedSecret) ([]byte, error) {
 	return json.Marshal(sealedSecret)
 }
+
+// encodeIndented encodes a sealed secret indented, as Seal writes it
+func encodeIndented(sealedSecret *SealedSecret) ([]byte, error) {
+	return json.MarshalIndent(sealedSecret, "", "  ")
+}
@@ -0,0 +1,108 @@
+package amnesia
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/sync/errgroup"
+)
+
+const kdfKeyLen = uint32(32)
+
+// DefaultMemoryBudget is the default amount of memory in bytes that concurrent
+// KDF evaluations may use
+const DefaultMemoryBudget = 256 * 1024 * 1024
+
+// KDFParams are the argon2id cost parameters
+type KDFParams struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // KiB
+	Threads uint8  `json:"threads"`
+}
+
+// Upper bounds on the KDF parameters. They're read from sealed files, so a
+// crafted file could otherwise exhaust memory or hang unsealing.
+const (
+	MaxKDFTime   = 64
+	MaxKDFMemory = 4 * 1024 * 1024 // 4GiB in KiB
+)
+
+var DefaultKDFParams = KDFParams{
+	Time:    5,
+	Memory:  64 * 1024, // 64MiB
+	Threads: 4,
+}
+
+func (p KDFParams) Validate() error {
+	if p.Time < 1 {
+		return fmt.Errorf("kdf time must be at least 1")
+	}
+	if p.Threads < 1 {
+		return fmt.Errorf("kdf threads must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Threads) {
+		return fmt.Errorf("kdf memory must be at least 8KiB per thread")
+	}
+	if p.Time > MaxKDFTime {
+		return fmt.Errorf("kdf time must be at most %d", MaxKDFTime)
+	}
+	if p.Memory > MaxKDFMemory {
+		return fmt.Errorf("kdf memory must be at most %dKiB", MaxKDFMemory)
+	}
+	return nil
+}
+
+func (p KDFParams) derive(password, salt []byte) []byte {
+	return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, kdfKeyLen)
+}
+
+type kdfInput struct {
+	password []byte
+	salt     []byte
+}
+
+// deriveKeys evaluates the KDF for each input concurrently, limited by the
+// memory budget. Cancellation is checked before each evaluation starts since
+// argon2 itself can't be interrupted.
+func (s *Sealer) deriveKeys(ctx context.Context, params KDFParams, inputs []kdfInput) ([][]byte, error) {
+	keys := make([][]byte, len(inputs))
+
+	limit := int(s.options.memoryBudget / (uint64(params.Memory) * 1024))
+	limit = max(1, min(limit, len(inputs)))
+
+	var (
+		mu   sync.Mutex
+		done int
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(limit)
+
+	for i, input := range inputs {
+		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+
+			keys[i] = params.derive(input.password, input.salt)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			done++
+			if s.options.progress != nil {
+				s.options.progress(done, len(inputs))
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
@@ -1,25 +1,28 @@
 package amnesia
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
 	"time"
 
-	"github.com/hashicorp/vault/shamir"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 )
 
 // encryptShare encrypts a share of the DEK with AES-CTR
-func encryptShare(data, key []byte) []byte {
+func (s *Sealer) encryptShare(data, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key[:32])
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	iv := make([]byte, aes.BlockSize)
-	if _, err := rand.Read(iv); err != nil {
-		panic(err)
+	iv, err := s.random(aes.BlockSize)
+	if err != nil {
+		return nil, err
 	}
 
 	stream := cipher.NewCTR(block, iv)
@@ -30,24 +33,24 @@ func encryptShare(data, key []byte) []byte {
 	result = append(result, iv...)
 	result = append(result, ciphertext...)
 
-	return result
+	return result, nil
 }
 
 // encryptData encrypts data with AES-GCM using the DEK
-func encryptData(data []byte, key []byte) []byte {
+func (s *Sealer) encryptData(data []byte, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key[:32])
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := rand.Read(nonce); err != nil {
-		panic(err)
+	nonce, err := s.random(gcm.NonceSize())
+	if err != nil {
+		return nil, err
 	}
 
 	ciphertext := gcm.Seal(nil, nonce, data, nil)
@@ -56,10 +59,11 @@ func encryptData(data []byte, key []byte) []byte {
 	result = append(result, nonce...)
 	result = append(result, ciphertext...)
 
-	return result
+	return result, nil
 }
 
-func Seal(
+func (s *Sealer) Seal(
+	ctx context.Context,
 	secret []byte,
 	questions Questions,
 	threshold int,
@@ -67,56 +71,246 @@ func Seal(
 	if err := questions.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.options.kdfParams.Validate(); err != nil {
+		return nil, err
+	}
 
-	return sealV1(secret, questions, threshold)
+	switch s.options.formatVersion {
+	case FormatV1:
+		if s.options.kdfParams != DefaultKDFParams {
+			return nil, ErrUnsupportedKDFParams
+		}
+		if s.options.keyfile != nil {
+			return nil, ErrUnsupportedKeyfile
+		}
+		if s.options.trustees != nil {
+			return nil, ErrUnsupportedTrustees
+		}
+		if s.options.bip39 {
+			return nil, ErrUnsupportedBIP39
+		}
+	case FormatV2, FormatV3:
+	default:
+		return nil, fmt.Errorf("unknown version: %s", s.options.formatVersion)
+	}
+
+	return s.seal(ctx, secret, questions, threshold)
 }
 
-func ResealWithKey(sealed, secret, key []byte) ([]byte, error) {
+func (s *Sealer) ResealWithKey(sealed, secret, key []byte) ([]byte, error) {
 	sealedSecret, err := Decode(sealed)
 	if err != nil {
 		return nil, err
 	}
-	sealedSecret.Encrypted = encryptData(secret, key)
+
+	secret, wipe, err := encodeSecret(sealedSecret.SecretFormat, secret)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe()
+
+	sealedSecret.Encrypted, err = s.encryptData(secret, key)
+	if err != nil {
+		return nil, err
+	}
 
 	return Encode(sealedSecret)
 }
 
-func sealV1(
+func (s *Sealer) seal(
+	ctx context.Context,
 	secret []byte,
 	questions Questions,
 	threshold int,
 ) ([]byte, error) {
 	sealedSecret := SealedSecret{
-		Version:         "1",
-		SealedTimestamp: time.Now().Format(time.RFC3339),
-		Shares:          make([]Share, 0, len(questions)),
+		Version:         s.options.formatVersion,
+		SealedTimestamp: s.options.now().Format(time.RFC3339),
 	}
 
-	// DEK encryption key for secret
-	dekKey := random(32)
-	sealedSecret.Encrypted = encryptData(secret, dekKey)
+	if s.options.bip39 {
+		sealedSecret.SecretFormat = SecretBIP39
+	}
 
-	// Split DEK encryption key into shares
-	shares, err := shamir.Split(dekKey, len(questions), threshold)
+	secret, wipe, err := encodeSecret(sealedSecret.SecretFormat, secret)
 	if err != nil {
 		return nil, err
 	}
+	defer wipe()
 
-	for id, question := range questions {
-		idx := len(sealedSecret.Shares)
+	if s.options.formatVersion == FormatV3 {
+		return s.sealSlots(ctx, &sealedSecret, secret, questions, threshold)
+	}
 
-		// Encryption key/salt for KEK share
-		kekSalt := random(32)
-		kekKey := kdf([]byte(question.Answer), kekSalt)
-		encryptedShare := encryptShare(shares[idx], kekKey)
+	// Secret to split into shares, which is the DEK unless a keyfile is used
+	joined, dekKey, err := s.newShareSetKey(&sealedSecret.ShareSet, s.options.keyfile)
+	if err != nil {
+		return nil, err
+	}
+	defer joined.Destroy()
+	defer dekKey.Destroy()
+
+	sealedSecret.Encrypted, err = s.encryptData(secret, dekKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.splitShareSet(ctx, &sealedSecret.ShareSet, joined.Bytes(), questions, s.options.trustees, threshold, 0); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(sealedSecret, "", "  ")
+}
 
-		sealedSecret.Shares = append(sealedSecret.Shares, Share{
-			ID:       id,
-			Question: question.Question,
-			Salt:     encoding.EncodeToString(kekSalt),
+// sealSlots seals the secret under a random DEK wrapped by a single questions
+// slot
+func (s *Sealer) sealSlots(
+	ctx context.Context,
+	sealedSecret *SealedSecret,
+	secret []byte,
+	questions Questions,
+	threshold int,
+) ([]byte, error) {
+	dek := secmem.New(32)
+	defer dek.Destroy()
+
+	if _, err := io.ReadFull(s.options.rand, dek.Bytes()); err != nil {
+		return nil, fmt.Errorf("error reading random bytes: %w", err)
+	}
+
+	var err error
+
+	sealedSecret.Encrypted, err = s.encryptData(secret, dek.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	slot, err := s.newSlot(ctx, 0, 0, dek.Bytes(), SlotSpec{
+		Type:      SlotQuestions,
+		Questions: questions,
+		Threshold: threshold,
+		Trustees:  s.options.trustees,
+		Keyfile:   s.options.keyfile,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sealedSecret.Slots = []Slot{*slot}
+
+	return json.MarshalIndent(sealedSecret, "", "  ")
+}
+
+// newShareSetKey generates the secret to split into shares and the key it
+// protects, which differs from the secret when a keyfile is mixed in. Both
+// buffers must be destroyed by the caller.
+func (s *Sealer) newShareSetKey(set *ShareSet, keyfile []byte) (*secmem.Buffer, *secmem.Buffer, error) {
+	joined := secmem.New(32)
+
+	if _, err := io.ReadFull(s.options.rand, joined.Bytes()); err != nil {
+		joined.Destroy()
+		return nil, nil, fmt.Errorf("error reading random bytes: %w", err)
+	}
+
+	if keyfile == nil {
+		key := secmem.New(joined.Len())
+		copy(key.Bytes(), joined.Bytes())
+
+		return joined, key, nil
+	}
+
+	params, key, err := s.sealKeyfile(keyfile, joined.Bytes())
+	if err != nil {
+		joined.Destroy()
+		return nil, nil, err
+	}
+	set.Keyfile = params
+
+	return joined, key, nil
+}
+
+// splitShareSet splits joined into shares, each encrypted with a key derived
+// from the answer to a question or to a trustee's age recipient. Share IDs are
+// offset so they stay unique across slots, and trustees follow the questions.
+func (s *Sealer) splitShareSet(
+	ctx context.Context,
+	set *ShareSet,
+	joined []byte,
+	questions Questions,
+	trustees []string,
+	threshold int,
+	offset int,
+) error {
+	set.Threshold = threshold
+	set.Shares = make([]Share, 0, len(questions)+len(trustees))
+
+	if s.options.formatVersion != FormatV1 {
+		params := s.options.kdfParams
+		set.KDF = &params
+	}
+
+	shares, err := splitSecret(s.options.rand, joined, len(questions)+len(trustees), threshold)
+	if err != nil {
+		return err
+	}
+	defer wipeAll(shares)
+
+	ids := make([]int, 0, len(questions))
+	for id := range questions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	// Encryption key/salt for each KEK share
+	inputs := make([]kdfInput, 0, len(ids))
+	for _, id := range ids {
+		salt, err := s.random(32)
+		if err != nil {
+			return err
+		}
+
+		inputs = append(inputs, kdfInput{
+			password: questions[id].Answer,
+			salt:     salt,
+		})
+	}
+
+	kekKeys, err := s.deriveKeys(ctx, s.options.kdfParams, inputs)
+	if err != nil {
+		return err
+	}
+	defer wipeAll(kekKeys)
+
+	for idx, id := range ids {
+		encryptedShare, err := s.encryptShare(shares[idx], kekKeys[idx])
+		if err != nil {
+			return err
+		}
+
+		set.Shares = append(set.Shares, Share{
+			ID:       offset + id,
+			Question: questions[id].Question,
+			Salt:     encoding.EncodeToString(inputs[idx].salt),
 			Share:    encoding.EncodeToString(encryptedShare),
 		})
 	}
 
-	return json.MarshalIndent(sealedSecret, "", "  ")
+	next := offset
+	if len(ids) > 0 {
+		next += ids[len(ids)-1] + 1
+	}
+
+	for idx, recipient := range trustees {
+		encryptedShare, err := encryptAge(shares[len(ids)+idx], recipient)
+		if err != nil {
+			return err
+		}
+
+		set.Shares = append(set.Shares, Share{
+			ID:        next + idx,
+			Recipient: recipient,
+			Share:     encoding.EncodeToString(encryptedShare),
+		})
+	}
+
+	return nil
 }
@@ -1,8 +1,17 @@
 package amnesia
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"filippo.io/age"
+	"github.com/hashicorp/vault/shamir"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,7 +22,7 @@ func TestQuestions(t *testing.T) {
 		q := NewQuestions()
 		q.Set(0, Question{
 			Question: "What's your favourite animal?",
-			Answer:   "cat",
+			Answer:   []byte("cat"),
 		})
 		err := q.Validate()
 		assert.ErrorIs(t, err, ErrTooFewQuestions)
@@ -25,7 +34,7 @@ func TestQuestions(t *testing.T) {
 		for i := range MaxQuestions * 2 {
 			q.Set(i, Question{
 				Question: "What's your favourite animal?",
-				Answer:   "cat",
+				Answer:   []byte("cat"),
 			})
 		}
 
@@ -39,7 +48,7 @@ func TestQuestions(t *testing.T) {
 		for i := range 8 {
 			q.Set(i, Question{
 				Question: "What's your favourite animal?",
-				Answer:   "cat",
+				Answer:   []byte("cat"),
 			})
 		}
 
@@ -52,38 +61,705 @@ func TestSeal(t *testing.T) {
 	q := NewQuestions()
 	q.Set(0, Question{
 		Question: "What's your favourite animal?",
-		Answer:   "cat",
+		Answer:   []byte("cat"),
 	})
 	q.Set(1, Question{
 		Question: "What's your favourite food?",
-		Answer:   "pizza",
+		Answer:   []byte("pizza"),
 	})
 
-	sealed, err := Seal(testData, q, 2)
+	sealed, err := Seal(t.Context(), testData, q, 2)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, sealed)
+
+	sealedSecret, err := Decode(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sealedSecret.Threshold)
 }
 
 func TestUnseal(t *testing.T) {
 	q := NewQuestions()
 	q.Set(0, Question{
 		Question: "What's your favourite animal?",
-		Answer:   "cat",
+		Answer:   []byte("cat"),
 	})
 	q.Set(1, Question{
 		Question: "What's your favourite food?",
-		Answer:   "pizza",
+		Answer:   []byte("pizza"),
 	})
 
-	sealed, err := Seal(testData, q, 2)
+	sealed, err := Seal(t.Context(), testData, q, 2)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, sealed)
 
 	a := NewAnswers()
-	a.Set(0, "cat")
-	a.Set(1, "pizza")
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	unsealed, err := Unseal(t.Context(), sealed, a)
+	assert.NoError(t, err)
+	assert.Equal(t, testData, unsealed.Bytes())
+}
+
+func TestSealProgress(t *testing.T) {
+	q := NewQuestions()
+	q.Set(0, Question{
+		Question: "What's your favourite animal?",
+		Answer:   []byte("cat"),
+	})
+	q.Set(1, Question{
+		Question: "What's your favourite food?",
+		Answer:   []byte("pizza"),
+	})
+	q.Set(2, Question{
+		Question: "What's your favourite colour?",
+		Answer:   []byte("green"),
+	})
+
+	var calls []int
+
+	sealed, err := Seal(t.Context(), testData, q, 2, WithProgress(func(done, total int) {
+		assert.Equal(t, 3, total)
+		calls = append(calls, done)
+	}))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, sealed)
+	assert.Equal(t, []int{1, 2, 3}, calls)
+}
+
+func TestSealCancelled(t *testing.T) {
+	q := NewQuestions()
+	q.Set(0, Question{
+		Question: "What's your favourite animal?",
+		Answer:   []byte("cat"),
+	})
+	q.Set(1, Question{
+		Question: "What's your favourite food?",
+		Answer:   []byte("pizza"),
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := Seal(ctx, testData, q, 2)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+var testKDFParams = KDFParams{
+	Time:    1,
+	Memory:  64,
+	Threads: 1,
+}
+
+func testQuestions() Questions {
+	q := NewQuestions()
+	q.Set(0, Question{
+		Question: "What's your favourite animal?",
+		Answer:   []byte("cat"),
+	})
+	q.Set(1, Question{
+		Question: "What's your favourite food?",
+		Answer:   []byte("pizza"),
+	})
+	q.Set(2, Question{
+		Question: "What's your favourite colour?",
+		Answer:   []byte("green"),
+	})
+	return q
+}
+
+func testSealer(seed byte, opts ...Option) *Sealer {
+	return NewSealer(append([]Option{
+		WithRand(rand.NewChaCha8([32]byte{seed})),
+		WithClock(func() time.Time {
+			return time.Date(2025, 7, 16, 23, 55, 59, 0, time.UTC)
+		}),
+		WithKDFParams(testKDFParams),
+	}, opts...)...)
+}
+
+func TestSealer(t *testing.T) {
+	t.Run("Deterministic", func(t *testing.T) {
+		a, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+
+		b, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, a, b)
+
+		c, err := testSealer(2).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+		assert.NotEqual(t, a, c)
+	})
+
+	t.Run("RecordsKDFParams", func(t *testing.T) {
+		sealed, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+
+		sealedSecret, err := Decode(sealed)
+		assert.NoError(t, err)
+		assert.Equal(t, FormatV2, sealedSecret.Version)
+		assert.Equal(t, "2025-07-16T23:55:59Z", sealedSecret.SealedTimestamp)
+		assert.Equal(t, &testKDFParams, sealedSecret.KDF)
+
+		a := NewAnswers()
+		a.Set(0, []byte("cat"))
+		a.Set(2, []byte("green"))
+
+		unsealed, err := Unseal(t.Context(), sealed, a)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+	})
 
-	unsealed, err := Unseal(sealed, a)
+	t.Run("FormatV1", func(t *testing.T) {
+		_, err := NewSealer(
+			WithFormatVersion(FormatV1),
+			WithKDFParams(testKDFParams),
+		).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.ErrorIs(t, err, ErrUnsupportedKDFParams)
+	})
+
+	t.Run("RandError", func(t *testing.T) {
+		_, err := NewSealer(
+			WithRand(iotest.ErrReader(errors.New("no entropy"))),
+			WithKDFParams(testKDFParams),
+		).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.Error(t, err)
+	})
+}
+
+func TestKDFParamsLimits(t *testing.T) {
+	assert.NoError(t, DefaultKDFParams.Validate())
+	assert.NoError(t, KDFParams{Time: MaxKDFTime, Memory: MaxKDFMemory, Threads: 255}.Validate())
+	assert.Error(t, KDFParams{Time: MaxKDFTime + 1, Memory: 64, Threads: 1}.Validate())
+	assert.Error(t, KDFParams{Time: 1, Memory: MaxKDFMemory + 1, Threads: 1}.Validate())
+
+	sealed, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+	assert.NoError(t, err)
+
+	sealedSecret, err := Decode(sealed)
 	assert.NoError(t, err)
-	assert.Equal(t, testData, unsealed)
+
+	t.Run("SealedFile", func(t *testing.T) {
+		crafted := *sealedSecret
+		crafted.KDF = &KDFParams{Time: 1, Memory: 1 << 31, Threads: 1}
+
+		data, err := Encode(&crafted)
+		assert.NoError(t, err)
+
+		_, err = Decode(data)
+		assert.ErrorContains(t, err, "kdf memory")
+	})
+
+	t.Run("Slot", func(t *testing.T) {
+		sealed, err := testSealer(1, WithFormatVersion(FormatV3)).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+
+		crafted, err := Decode(sealed)
+		assert.NoError(t, err)
+		crafted.Slots[0].KDF = &KDFParams{Time: 1 << 20, Memory: 64, Threads: 1}
+
+		data, err := Encode(crafted)
+		assert.NoError(t, err)
+
+		_, err = Decode(data)
+		assert.ErrorContains(t, err, "kdf time")
+	})
+}
+
+func TestSplitSecret(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	shares, err := splitSecret(rand.NewChaCha8([32]byte{}), secret, 5, 3)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 5)
+
+	combined, err := shamir.Combine([][]byte{shares[4], shares[0], shares[2]})
+	assert.NoError(t, err)
+	assert.Equal(t, secret, combined)
+
+	combined, err = shamir.Combine([][]byte{shares[0], shares[1]})
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, combined)
+}
+
+func TestKeyfile(t *testing.T) {
+	keyfile := []byte("contents of a file on a usb stick")
+
+	sealed, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+	assert.NoError(t, err)
+
+	withKeyfile, err := NewSealer(
+		WithKDFParams(testKDFParams),
+		WithKeyfile(keyfile),
+	).Seal(t.Context(), testData, testQuestions(), 2)
+	assert.NoError(t, err)
+
+	sealedSecret, err := Decode(withKeyfile)
+	assert.NoError(t, err)
+	assert.True(t, sealedSecret.RequiresKeyfile())
+
+	a := NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	t.Run("Required", func(t *testing.T) {
+		_, err := Unseal(t.Context(), withKeyfile, a)
+		assert.ErrorIs(t, err, ErrKeyfileRequired)
+	})
+
+	t.Run("Incorrect", func(t *testing.T) {
+		_, err := Unseal(t.Context(), withKeyfile, a, WithKeyfile([]byte("wrong")))
+		assert.ErrorIs(t, err, ErrIncorrectKeyfile)
+	})
+
+	t.Run("Correct", func(t *testing.T) {
+		unsealed, err := Unseal(t.Context(), withKeyfile, a, WithKeyfile(keyfile))
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+	})
+
+	t.Run("Ignored", func(t *testing.T) {
+		unsealed, err := Unseal(t.Context(), sealed, a, WithKeyfile(keyfile))
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+	})
+
+	t.Run("FormatV1", func(t *testing.T) {
+		_, err := NewSealer(
+			WithFormatVersion(FormatV1),
+			WithKeyfile(keyfile),
+		).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.ErrorIs(t, err, ErrUnsupportedKeyfile)
+	})
+}
+
+func TestSlots(t *testing.T) {
+	sealed, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+	assert.NoError(t, err)
+
+	a := NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	sealedSecret, err := Decode(sealed)
+	assert.NoError(t, err)
+
+	key, err := DecryptKey(t.Context(), sealedSecret, a)
+	assert.NoError(t, err)
+	defer key.Destroy()
+
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	upgraded, err := AddSlot(t.Context(), sealed, key.Bytes(), SlotSpec{
+		Type:      SlotAge,
+		Recipient: identity.Recipient().String(),
+	}, WithKDFParams(testKDFParams))
+	assert.NoError(t, err)
+
+	partner := NewQuestions()
+	partner.Set(0, Question{Question: "Where did we meet?", Answer: []byte("paris")})
+	partner.Set(1, Question{Question: "First pet's name?", Answer: []byte("rex")})
+
+	// Key changes when upgrading so it has to be recovered again
+	upgradedSecret, err := Decode(upgraded)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatV3, upgradedSecret.Version)
+	assert.Len(t, upgradedSecret.Slots, 2)
+
+	newKey, err := DecryptKey(t.Context(), upgradedSecret, nil, WithIdentities(identity))
+	assert.NoError(t, err)
+	defer newKey.Destroy()
+	assert.NotEqual(t, key.Bytes(), newKey.Bytes())
+
+	withPartner, err := AddSlot(t.Context(), upgraded, newKey.Bytes(), SlotSpec{
+		Type:      SlotQuestions,
+		Label:     "partner",
+		Questions: partner,
+		Threshold: 2,
+	}, WithKDFParams(testKDFParams))
+	assert.NoError(t, err)
+
+	t.Run("UniqueShareIDs", func(t *testing.T) {
+		sealedSecret, err := Decode(withPartner)
+		assert.NoError(t, err)
+
+		seen := make(map[int]bool)
+		for _, slot := range sealedSecret.Slots {
+			for _, share := range slot.Shares {
+				assert.False(t, seen[share.ID])
+				seen[share.ID] = true
+			}
+		}
+		assert.Len(t, seen, 5)
+	})
+
+	t.Run("EachSlot", func(t *testing.T) {
+		unsealed, err := Unseal(t.Context(), withPartner, a)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+
+		unsealed, err = Unseal(t.Context(), withPartner, nil, WithIdentities(identity))
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+
+		b := NewAnswers()
+		b.Set(3, []byte("paris"))
+		b.Set(4, []byte("rex"))
+
+		unsealed, err = Unseal(t.Context(), withPartner, b)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+	})
+
+	t.Run("WithSlot", func(t *testing.T) {
+		_, err := Unseal(t.Context(), withPartner, a, WithSlot(2))
+		assert.ErrorIs(t, err, ErrNoSlot)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		_, err := RemoveSlot(withPartner, key.Bytes(), 1)
+		assert.Error(t, err)
+
+		removed, err := RemoveSlot(withPartner, newKey.Bytes(), 1)
+		assert.NoError(t, err)
+
+		_, err = Unseal(t.Context(), removed, nil, WithIdentities(identity))
+		assert.ErrorIs(t, err, ErrNoSlot)
+
+		removed, err = RemoveSlot(removed, newKey.Bytes(), 0)
+		assert.NoError(t, err)
+
+		_, err = RemoveSlot(removed, newKey.Bytes(), 2)
+		assert.ErrorIs(t, err, ErrLastSlot)
+	})
+}
+
+func TestTrustees(t *testing.T) {
+	alice, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	bob, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	sealed, err := NewSealer(
+		WithKDFParams(testKDFParams),
+		WithTrustees(alice.Recipient().String(), bob.Recipient().String()),
+	).Seal(t.Context(), testData, testQuestions(), 3)
+	assert.NoError(t, err)
+
+	sealedSecret, err := Decode(sealed)
+	assert.NoError(t, err)
+	assert.Len(t, sealedSecret.Shares, 5)
+	assert.Equal(t, 3, sealedSecret.Shares[3].ID)
+	assert.True(t, sealedSecret.Shares[3].IsTrustee())
+
+	a := NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	t.Run("TooFew", func(t *testing.T) {
+		_, err := Unseal(t.Context(), sealed, a)
+		assert.ErrorIs(t, err, ErrTooFewAnswers)
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		unsealed, err := Unseal(t.Context(), sealed, a, WithIdentities(alice))
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+	})
+
+	t.Run("DecryptedShares", func(t *testing.T) {
+		aliceShare, err := DecryptTrusteeShare(sealedSecret, alice)
+		assert.NoError(t, err)
+		bobShare, err := DecryptTrusteeShare(sealedSecret, bob)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, bobShare.ID)
+
+		encoded, err := bobShare.Encode()
+		assert.NoError(t, err)
+		bobShare, err = ParseTrusteeShare(encoded)
+		assert.NoError(t, err)
+
+		b := NewAnswers()
+		b.Set(2, []byte("green"))
+
+		unsealed, err := Unseal(t.Context(), sealed, b, WithTrusteeShares(aliceShare, bobShare))
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+	})
+
+	t.Run("NoMatch", func(t *testing.T) {
+		other, err := age.GenerateX25519Identity()
+		assert.NoError(t, err)
+
+		_, err = DecryptTrusteeShare(sealedSecret, other)
+		assert.Error(t, err)
+	})
+
+	t.Run("FormatV1", func(t *testing.T) {
+		_, err := NewSealer(
+			WithFormatVersion(FormatV1),
+			WithTrustees(alice.Recipient().String()),
+		).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.ErrorIs(t, err, ErrUnsupportedTrustees)
+	})
+}
+
+func TestShareFile(t *testing.T) {
+	sealed, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+	assert.NoError(t, err)
+
+	exported, updated, err := ExportShare(sealed, 1, true)
+	assert.NoError(t, err)
+
+	a := NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	t.Run("Removed", func(t *testing.T) {
+		_, err := Unseal(t.Context(), updated, a)
+		assert.ErrorIs(t, err, ErrTooFewAnswers)
+
+		b := NewAnswers()
+		b.Set(0, []byte("cat"))
+		b.Set(2, []byte("green"))
+
+		unsealed, err := Unseal(t.Context(), updated, b)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+
+		_, _, err = ExportShare(updated, 1, true)
+		assert.Error(t, err)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		imported, err := ImportShare(updated, exported)
+		assert.NoError(t, err)
+
+		unsealed, err := Unseal(t.Context(), imported, a)
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+
+		original, err := Decode(sealed)
+		assert.NoError(t, err)
+		restored, err := Decode(imported)
+		assert.NoError(t, err)
+		assert.Equal(t, original, restored)
+
+		// Written out the same way as when it was sealed
+		assert.Equal(t, string(sealed), string(imported))
+	})
+
+	t.Run("Checksum", func(t *testing.T) {
+		tampered := bytes.Replace(exported, []byte(`"threshold": 2`), []byte(`"threshold": 3`), 1)
+		assert.NotEqual(t, exported, tampered)
+
+		_, err := ImportShare(updated, tampered)
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("OtherFile", func(t *testing.T) {
+		other, err := testSealer(2).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+
+		_, err = ImportShare(other, exported)
+		assert.Error(t, err)
+	})
+
+	t.Run("Resealed", func(t *testing.T) {
+		// Resealing doesn't change the fingerprint
+		resealed, err := ResealWithKey(updated, []byte("vim > zed"), make([]byte, 32))
+		assert.NoError(t, err)
+
+		_, err = ImportShare(resealed, exported)
+		assert.NoError(t, err)
+	})
+}
+
+func TestCeremony(t *testing.T) {
+	sealed, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+	assert.NoError(t, err)
+
+	sealedSecret, err := Decode(sealed)
+	assert.NoError(t, err)
+
+	coordinator, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	recipient := coordinator.Recipient().String()
+
+	var contributions [][]byte
+	for id, answer := range map[int]string{0: "cat", 2: "green"} {
+		a := NewAnswers()
+		a.Set(id, []byte(answer))
+
+		contribution, err := Contribute(t.Context(), sealedSecret, a, recipient)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(contribution), answer)
+
+		contributions = append(contributions, contribution)
+	}
+
+	t.Run("Combine", func(t *testing.T) {
+		shares, err := OpenContributions(sealedSecret, contributions, coordinator)
+		assert.NoError(t, err)
+		assert.Len(t, shares, 2)
+
+		unsealed, err := Unseal(t.Context(), sealed, NewAnswers(), WithTrusteeShares(shares...))
+		assert.NoError(t, err)
+		assert.Equal(t, testData, unsealed.Bytes())
+	})
+
+	t.Run("TooFew", func(t *testing.T) {
+		shares, err := OpenContributions(sealedSecret, contributions[:1], coordinator)
+		assert.NoError(t, err)
+
+		_, err = Unseal(t.Context(), sealed, NewAnswers(), WithTrusteeShares(shares...))
+		assert.ErrorIs(t, err, ErrTooFewAnswers)
+	})
+
+	t.Run("WrongIdentity", func(t *testing.T) {
+		other, err := age.GenerateX25519Identity()
+		assert.NoError(t, err)
+
+		_, err = OpenContributions(sealedSecret, contributions, other)
+		assert.Error(t, err)
+	})
+
+	t.Run("OtherFile", func(t *testing.T) {
+		other, err := testSealer(2).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+
+		otherSecret, err := Decode(other)
+		assert.NoError(t, err)
+
+		_, err = OpenContributions(otherSecret, contributions, coordinator)
+		assert.Error(t, err)
+	})
+}
+
+func TestBIP39(t *testing.T) {
+	mnemonic := "legal winner thank year wave sausage worth useful legal winner thank yellow"
+
+	sealed, err := testSealer(1, WithBIP39()).Seal(t.Context(), []byte(strings.ToUpper(mnemonic)+"\n"), testQuestions(), 2)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(sealed), "legal")
+
+	sealedSecret, err := Decode(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, SecretBIP39, sealedSecret.SecretFormat)
+
+	a := NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	unsealed, err := Unseal(t.Context(), sealed, a)
+	assert.NoError(t, err)
+	assert.Equal(t, mnemonic+"\n", string(unsealed.Bytes()))
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := testSealer(1, WithBIP39()).Seal(t.Context(), []byte("legal winner thank year wave sausage worth useful legal winner thank thank"), testQuestions(), 2)
+		assert.Error(t, err)
+
+		_, err = testSealer(1, WithBIP39()).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.Error(t, err)
+	})
+
+	t.Run("Reseal", func(t *testing.T) {
+		resealed, err := ResealWithKey(sealed, []byte("vim > zed"), make([]byte, 32))
+		assert.Error(t, err)
+		assert.Nil(t, resealed)
+	})
+
+	t.Run("AddSlot", func(t *testing.T) {
+		key, err := DecryptKey(t.Context(), sealedSecret, a)
+		assert.NoError(t, err)
+		defer key.Destroy()
+
+		passphrase := []byte("correct horse battery staple")
+
+		upgraded, err := AddSlot(t.Context(), sealed, key.Bytes(), SlotSpec{
+			Type:       SlotPassphrase,
+			Passphrase: passphrase,
+		}, WithKDFParams(testKDFParams))
+		assert.NoError(t, err)
+
+		unsealed, err := Unseal(t.Context(), upgraded, a)
+		assert.NoError(t, err)
+		assert.Equal(t, mnemonic+"\n", string(unsealed.Bytes()))
+
+		unsealed, err = Unseal(t.Context(), upgraded, nil, WithPassphrase(passphrase))
+		assert.NoError(t, err)
+		assert.Equal(t, mnemonic+"\n", string(unsealed.Bytes()))
+	})
+
+	t.Run("V1", func(t *testing.T) {
+		_, err := NewSealer(WithBIP39(), WithFormatVersion(FormatV1)).Seal(t.Context(), []byte(mnemonic), testQuestions(), 2)
+		assert.ErrorIs(t, err, ErrUnsupportedBIP39)
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	sealed, err := testSealer(1).Seal(t.Context(), testData, testQuestions(), 2)
+	assert.NoError(t, err)
+
+	sealedSecret, err := Decode(sealed)
+	assert.NoError(t, err)
+
+	a := NewAnswers()
+	a.Set(0, []byte("cat"))
+	a.Set(1, []byte("pizza"))
+
+	key, err := DecryptKey(t.Context(), sealedSecret, a)
+	assert.NoError(t, err)
+	defer key.Destroy()
+
+	assert.NoError(t, VerifyKey(sealedSecret, key.Bytes()))
+	assert.Error(t, VerifyKey(sealedSecret, make([]byte, 32)))
+	assert.Error(t, VerifyKey(sealedSecret, nil))
+
+	t.Run("Reseal", func(t *testing.T) {
+		resealed, err := ResealWithKey(sealed, []byte("vim > zed"), key.Bytes())
+		assert.NoError(t, err)
+
+		resealedSecret, err := Decode(resealed)
+		assert.NoError(t, err)
+		assert.Equal(t, sealedSecret.Fingerprint(), resealedSecret.Fingerprint())
+	})
+
+	t.Run("Different", func(t *testing.T) {
+		other, err := testSealer(2).Seal(t.Context(), testData, testQuestions(), 2)
+		assert.NoError(t, err)
+
+		otherSecret, err := Decode(other)
+		assert.NoError(t, err)
+		assert.NotEqual(t, sealedSecret.Fingerprint(), otherSecret.Fingerprint())
+	})
+
+	t.Run("Slots", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		assert.NoError(t, err)
+
+		upgraded, err := AddSlot(t.Context(), sealed, key.Bytes(), SlotSpec{
+			Type:      SlotAge,
+			Recipient: identity.Recipient().String(),
+		}, WithKDFParams(testKDFParams))
+		assert.NoError(t, err)
+
+		upgradedSecret, err := Decode(upgraded)
+		assert.NoError(t, err)
+		assert.NotEqual(t, sealedSecret.Fingerprint(), upgradedSecret.Fingerprint())
+
+		upgradedKey, err := DecryptKey(t.Context(), upgradedSecret, nil, WithIdentities(identity))
+		assert.NoError(t, err)
+		defer upgradedKey.Destroy()
+
+		resealed, err := ResealWithKey(upgraded, []byte("vim > zed"), upgradedKey.Bytes())
+		assert.NoError(t, err)
+
+		resealedSecret, err := Decode(resealed)
+		assert.NoError(t, err)
+		assert.Equal(t, upgradedSecret.Fingerprint(), resealedSecret.Fingerprint())
+	})
 }
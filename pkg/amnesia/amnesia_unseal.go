@@ -1,10 +1,12 @@
 package amnesia
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"fmt"
 
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 	"github.com/hashicorp/vault/shamir"
 )
 
@@ -29,8 +31,8 @@ func decryptShare(data []byte, key []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-// decryptData decrypts the data with AES-GCM using the DEK
-func decryptData(data []byte, key []byte) ([]byte, error) {
+// decryptData decrypts the data with AES-GCM using the DEK into locked memory
+func decryptData(data []byte, key []byte) (*secmem.Buffer, error) {
 	if len(data) < aes.BlockSize {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
@@ -45,90 +47,155 @@ func decryptData(data []byte, key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	if len(data) < aesgcm.NonceSize() {
+	if len(data) < aesgcm.NonceSize()+aesgcm.Overhead() {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
 
 	nonce := data[:aesgcm.NonceSize()]
 	ciphertext := data[aesgcm.NonceSize():]
 
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
+	plaintext := secmem.New(len(ciphertext) - aesgcm.Overhead())
+
+	if _, err := aesgcm.Open(plaintext.Bytes()[:0], nonce, ciphertext, nil); err != nil {
+		plaintext.Destroy()
 		return nil, err
 	}
 
 	return plaintext, nil
 }
 
-func Unseal(input []byte, answers Answers) ([]byte, error) {
-	if err := answers.Validate(); err != nil {
-		return nil, err
-	}
-
+func (s *Sealer) Unseal(ctx context.Context, input []byte, answers Answers) (*secmem.Buffer, error) {
 	sealed, err := Decode(input)
 	if err != nil {
 		return nil, err
 	}
 
 	switch sealed.Version {
-	case "1":
-		return unsealV1(sealed, answers)
+	case FormatV1, FormatV2, FormatV3:
+		if len(answers) > MaxQuestions {
+			return nil, ErrTooManyAnswers
+		}
+
+		return s.unseal(ctx, sealed, answers)
 	default:
 		return nil, fmt.Errorf("unknown version: %s", sealed.Version)
 	}
 }
 
-func UnsealWithKey(input, key []byte) ([]byte, error) {
+func UnsealWithKey(input, key []byte) (*secmem.Buffer, error) {
 	sealed, err := Decode(input)
 	if err != nil {
 		return nil, err
 	}
 
 	switch sealed.Version {
-	case "1":
-		return unsealV1WithKey(sealed, key)
+	case FormatV1, FormatV2, FormatV3:
+		return unsealWithKey(sealed, key)
 	default:
 		return nil, fmt.Errorf("unknown version: %s", sealed.Version)
 	}
 }
 
-func unsealV1(sealedSecret *SealedSecret, answers Answers) ([]byte, error) {
-	dekKey, err := DecryptKey(sealedSecret, answers)
+// VerifyKey checks that the key decrypts the sealed secret, such as a DEK
+// remembered from an earlier unseal
+func VerifyKey(sealedSecret *SealedSecret, key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("invalid key length")
+	}
+
+	secret, err := decryptData(sealedSecret.Encrypted, key)
+	if err != nil {
+		return fmt.Errorf("key doesn't decrypt the secret")
+	}
+	secret.Destroy()
+
+	return nil
+}
+
+func (s *Sealer) unseal(ctx context.Context, sealedSecret *SealedSecret, answers Answers) (*secmem.Buffer, error) {
+	dekKey, err := s.DecryptKey(ctx, sealedSecret, answers)
 	if err != nil {
 		return nil, err
 	}
+	defer dekKey.Destroy()
 
-	return unsealV1WithKey(sealedSecret, dekKey)
+	return unsealWithKey(sealedSecret, dekKey.Bytes())
 }
 
-func unsealV1WithKey(sealedSecret *SealedSecret, key []byte) ([]byte, error) {
+func unsealWithKey(sealedSecret *SealedSecret, key []byte) (*secmem.Buffer, error) {
 	secret, err := decryptData(sealedSecret.Encrypted, key)
 	if err != nil {
 		return nil, fmt.Errorf("error decrypting data (incorrect or too few answers?)")
 	}
 
-	return secret, nil
+	return decodeSecret(sealedSecret.SecretFormat, secret)
 }
 
-func DecryptKey(sealedSecret *SealedSecret, answers Answers) ([]byte, error) {
+// DecryptKey recovers the DEK from the answers, or for version 3 from any slot
+// the given credentials unlock. The caller must destroy the returned buffer.
+func (s *Sealer) DecryptKey(ctx context.Context, sealedSecret *SealedSecret, answers Answers) (*secmem.Buffer, error) {
 	switch sealedSecret.Version {
-	case "1":
-		return decryptKeyV1(sealedSecret, answers)
+	case FormatV1, FormatV2:
+		return s.decryptKey(ctx, sealedSecret, answers)
+	case FormatV3:
+		return s.openSlots(ctx, sealedSecret, answers)
 	default:
 		return nil, fmt.Errorf("unknown version: %s", sealedSecret.Version)
 	}
 }
 
-func decryptKeyV1(sealedSecret *SealedSecret, answers Answers) ([]byte, error) {
-	var shares [][]byte
+func (s *Sealer) decryptKey(ctx context.Context, sealedSecret *SealedSecret, answers Answers) (*secmem.Buffer, error) {
+	params, err := sealedSecret.kdfParams()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.openShareSet(ctx, &sealedSecret.ShareSet, params, answers)
+}
+
+// openShareSet recovers the key protected by a share set from the answers and
+// any shares already decrypted
+func (s *Sealer) openShareSet(ctx context.Context, set *ShareSet, params KDFParams, answers Answers) (*secmem.Buffer, error) {
+	// Check the keyfile first so a missing or incorrect one fails fast
+	keyfileKey, keyfileSalt, err := s.openKeyfile(set.Keyfile)
+	if err != nil {
+		return nil, err
+	}
+	defer keyfileKey.Destroy()
+
+	var (
+		inputs      []kdfInput
+		ciphertexts [][]byte
+		shares      [][]byte
+	)
+	defer func() { wipeAll(shares) }()
+
+	for _, share := range set.Shares {
+		if decryptedShare := s.decryptedShare(share.ID); decryptedShare != nil {
+			shares = append(shares, decryptedShare)
+			continue
+		}
+		if share.Exported {
+			// Share has to be imported from its share file first
+			continue
+		}
+		if share.IsTrustee() {
+			decryptedShare, err := s.trusteeShare(&share)
+			if err != nil {
+				return nil, err
+			}
+			if decryptedShare != nil {
+				shares = append(shares, decryptedShare)
+			}
+			continue
+		}
 
-	for _, share := range sealedSecret.Shares {
 		answer, ok := answers[share.ID]
 		if !ok {
 			// Missing answer, skip decrypting this share
 			continue
 		}
-		if answer == "" {
+		if len(answer) == 0 {
 			// Blank answer, user doesn't know it so skip decrypting this share
 			continue
 		}
@@ -143,8 +210,26 @@ func decryptKeyV1(sealedSecret *SealedSecret, answers Answers) ([]byte, error) {
 			return nil, err
 		}
 
-		key := kdf([]byte(answer), salt)
-		decryptedShare, err := decryptShare(ciphertext, key)
+		inputs = append(inputs, kdfInput{
+			password: answer,
+			salt:     salt,
+		})
+		ciphertexts = append(ciphertexts, ciphertext)
+	}
+
+	// Don't spend time on the KDF if the shares can't be combined anyway
+	if len(inputs)+len(shares) < max(set.Threshold, MinQuestions) {
+		return nil, ErrTooFewAnswers
+	}
+
+	keys, err := s.deriveKeys(ctx, params, inputs)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeAll(keys)
+
+	for i, ciphertext := range ciphertexts {
+		decryptedShare, err := decryptShare(ciphertext, keys[i])
 		if err != nil {
 			return nil, err
 		}
@@ -152,10 +237,15 @@ func decryptKeyV1(sealedSecret *SealedSecret, answers Answers) ([]byte, error) {
 		shares = append(shares, decryptedShare)
 	}
 
-	dekKey, err := shamir.Combine(shares)
+	joined, err := shamir.Combine(shares)
 	if err != nil {
 		return nil, fmt.Errorf("error joining shares: %w", err)
 	}
+	defer secmem.Wipe(joined)
+
+	if keyfileKey != nil {
+		return mixKeyfile(joined, keyfileKey.Bytes(), keyfileSalt)
+	}
 
-	return dekKey, nil
+	return secmem.Copy(joined), nil
 }
@@ -0,0 +1,109 @@
+// Package amnesiatest provides known-answer test vectors for sealed files and a
+// conformance test which runs any unsealing implementation against them.
+//
+// The corpus covers each format version with valid files as well as negative
+// cases such as tampering and truncation which must fail to unseal.
+package amnesiatest
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"path"
+	"testing"
+)
+
+//go:embed testdata
+var corpus embed.FS
+
+const vectorsFile = "testdata/vectors.json"
+
+// Vector is a sealed file along with answers and the expected outcome of
+// unsealing it with them
+type Vector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	File        string `json:"file"`
+	Input
+	// Plaintext is the expected unsealed secret, unused if Error is set
+	Plaintext []byte `json:"plaintext"`
+	// Error is set if unsealing must fail
+	Error bool `json:"error,omitempty"`
+}
+
+// Sealed returns the sealed file for the vector
+func (v Vector) Sealed() ([]byte, error) {
+	return corpus.ReadFile(path.Join("testdata", v.File))
+}
+
+// Vectors returns all vectors in the corpus
+func Vectors() ([]Vector, error) {
+	buf, err := corpus.ReadFile(vectorsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var vectors []Vector
+	if err := json.Unmarshal(buf, &vectors); err != nil {
+		return nil, err
+	}
+
+	return vectors, nil
+}
+
+// Input is everything supplied by the user to unseal a file
+type Input struct {
+	// Answers keyed by share ID
+	Answers map[int]string `json:"answers"`
+	Keyfile []byte         `json:"keyfile,omitempty"`
+	// Passphrase for passphrase slots
+	Passphrase []byte `json:"passphrase,omitempty"`
+}
+
+// Unsealer is implemented by anything that can unseal a sealed file
+type Unsealer interface {
+	Unseal(ctx context.Context, sealed []byte, input Input) ([]byte, error)
+}
+
+// UnsealFunc adapts a function to an Unsealer
+type UnsealFunc func(ctx context.Context, sealed []byte, input Input) ([]byte, error)
+
+func (f UnsealFunc) Unseal(ctx context.Context, sealed []byte, input Input) ([]byte, error) {
+	return f(ctx, sealed, input)
+}
+
+// Run runs every vector in the corpus against the unsealer as a subtest
+func Run(t *testing.T, u Unsealer) {
+	t.Helper()
+
+	vectors, err := Vectors()
+	if err != nil {
+		t.Fatalf("error loading vectors: %v", err)
+	}
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			sealed, err := v.Sealed()
+			if err != nil {
+				t.Fatalf("error loading sealed file: %v", err)
+			}
+
+			unsealed, err := u.Unseal(t.Context(), sealed, v.Input)
+
+			if v.Error {
+				if err == nil {
+					t.Fatalf("expected error unsealing %s, got none", v.File)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("error unsealing %s: %v", v.File, err)
+			}
+			if !bytes.Equal(unsealed, v.Plaintext) {
+				t.Fatalf("unsealed %q, want %q", unsealed, v.Plaintext)
+			}
+		})
+	}
+}
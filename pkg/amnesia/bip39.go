@@ -0,0 +1,80 @@
+package amnesia
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cedws/amnesia/pkg/amnesia/bip39"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// SecretBIP39 marks a secret sealed as the entropy of a BIP-39 mnemonic. The
+// mnemonic is rebuilt when unsealing.
+const SecretBIP39 = "bip39"
+
+var ErrUnsupportedBIP39 = fmt.Errorf("format version %s doesn't support BIP-39 secrets", FormatV1)
+
+// ValidateBIP39 checks the mnemonic's words and checksum
+func ValidateBIP39(mnemonic []byte) error {
+	entropy, err := mnemonicEntropy(mnemonic)
+	if err != nil {
+		return err
+	}
+	entropy.Destroy()
+
+	return nil
+}
+
+// mnemonicEntropy validates a BIP-39 mnemonic and returns its 16 to 32 bytes
+// of entropy
+func mnemonicEntropy(mnemonic []byte) (*secmem.Buffer, error) {
+	entropy, err := bip39.EntropyFromMnemonic(strings.ToLower(string(mnemonic)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BIP-39 mnemonic: %w", err)
+	}
+
+	return secmem.Copy(entropy), nil
+}
+
+// entropyMnemonic rebuilds the BIP-39 mnemonic for the entropy, followed by a
+// newline
+func entropyMnemonic(entropy []byte) (*secmem.Buffer, error) {
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BIP-39 entropy: %w", err)
+	}
+
+	return secmem.Copy([]byte(mnemonic + "\n")), nil
+}
+
+// encodeSecret converts the secret to the form it's sealed in. The returned
+// func wipes any converted copy.
+func encodeSecret(format string, secret []byte) ([]byte, func(), error) {
+	switch format {
+	case "":
+		return secret, func() {}, nil
+	case SecretBIP39:
+		entropy, err := mnemonicEntropy(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		return entropy.Bytes(), entropy.Destroy, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown secret format: %s", format)
+	}
+}
+
+// decodeSecret converts an unsealed secret back from the form it's sealed in,
+// destroying the original if it's converted
+func decodeSecret(format string, secret *secmem.Buffer) (*secmem.Buffer, error) {
+	switch format {
+	case "":
+		return secret, nil
+	case SecretBIP39:
+		defer secret.Destroy()
+		return entropyMnemonic(secret.Bytes())
+	default:
+		secret.Destroy()
+		return nil, fmt.Errorf("unknown secret format: %s", format)
+	}
+}
@@ -0,0 +1,109 @@
+// Package bip39 converts between BIP-39 mnemonics and the entropy they encode,
+// using the English wordlist.
+package bip39
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	wordBits     = 11
+	minWords     = 12
+	maxWords     = 24
+	minEntropy   = 16
+	maxEntropy   = 32
+	wordlistSize = 1 << wordBits
+)
+
+//go:embed wordlist.txt
+var wordlistData string
+
+var (
+	wordlist = strings.Fields(wordlistData)
+	wordIdx  = func() map[string]int {
+		m := make(map[string]int, len(wordlist))
+		for i, word := range wordlist {
+			m[word] = i
+		}
+		return m
+	}()
+)
+
+// Wordlist returns the 2048 words of the English wordlist
+func Wordlist() []string {
+	return slices.Clone(wordlist)
+}
+
+// NewMnemonic encodes 16 to 32 bytes of entropy, a multiple of 4, as a
+// mnemonic of 12 to 24 words
+func NewMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < minEntropy || len(entropy) > maxEntropy || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("entropy must be 16, 20, 24, 28 or 32 bytes")
+	}
+
+	// The checksum is the first bit of the entropy's hash for every 4 bytes,
+	// so at most a byte
+	sum := sha256.Sum256(entropy)
+	data := append(slices.Clone(entropy), sum[0])
+	defer clear(data)
+
+	words := make([]string, (len(entropy)*8+len(entropy)/4)/wordBits)
+	for i := range words {
+		idx := 0
+		for j := range wordBits {
+			bit := i*wordBits + j
+			idx = idx<<1 | int(data[bit/8]>>(7-bit%8)&1)
+		}
+
+		words[i] = wordlist[idx]
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic checks the words and checksum of a mnemonic and returns
+// the entropy it encodes. Words are separated by whitespace and must be lower
+// case.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < minWords || len(words) > maxWords || len(words)%3 != 0 {
+		return nil, fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words")
+	}
+
+	bits := len(words) * wordBits
+	checksumBits := bits / 33
+	size := (bits - checksumBits) / 8
+
+	data := make([]byte, size+1)
+	for i, word := range words {
+		idx, ok := wordIdx[word]
+		if !ok {
+			clear(data)
+			// The word itself is left out, as it's part of the secret
+			return nil, fmt.Errorf("word %d isn't in the wordlist", i+1)
+		}
+
+		for j := range wordBits {
+			if idx>>(wordBits-1-j)&1 == 1 {
+				bit := i*wordBits + j
+				data[bit/8] |= 0x80 >> (bit % 8)
+			}
+		}
+	}
+
+	entropy, checksum := data[:size:size], data[size]
+	data[size] = 0
+
+	sum := sha256.Sum256(entropy)
+	mask := byte(0xff) << (8 - checksumBits)
+	if checksum&mask != sum[0]&mask {
+		clear(entropy)
+		return nil, fmt.Errorf("invalid checksum")
+	}
+
+	return entropy, nil
+}
@@ -0,0 +1,72 @@
+package bip39
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWordlist(t *testing.T) {
+	assert.Len(t, wordlist, wordlistSize)
+	assert.Len(t, wordIdx, wordlistSize)
+
+	prefixes := make(map[string]bool)
+	for _, word := range wordlist {
+		prefixes[word[:min(4, len(word))]] = true
+	}
+	assert.Len(t, prefixes, wordlistSize)
+}
+
+func TestVectors(t *testing.T) {
+	// From the BIP-39 test vectors
+	tests := []struct {
+		entropy  string
+		mnemonic string
+	}{
+		{"00000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"},
+		{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank yellow"},
+		{"9e885d952ad362caeb4efe34a8e91bd2", "ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic"},
+		{"6610b25967cdcca9d59875f5cb50b0ea75433311869e930b", "gravity machine north sort system female filter attitude volume fold club stay feature office ecology stable narrow fog"},
+		{"68a79eaca2324873eacc50cb9c6eca8cc68ea5d936f98787c60c7ebc74e6ce7c", "hamster diagram private dutch cause delay private meat slide toddler razor book happy fancy gospel tennis maple dilemma loan word shrug inflict delay length"},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entropy, func(t *testing.T) {
+			entropy, err := hex.DecodeString(tt.entropy)
+			require.NoError(t, err)
+
+			mnemonic, err := NewMnemonic(entropy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.mnemonic, mnemonic)
+
+			decoded, err := EntropyFromMnemonic(" " + strings.ReplaceAll(tt.mnemonic, " ", "\n ") + "\n")
+			require.NoError(t, err)
+			assert.Equal(t, entropy, decoded)
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := NewMnemonic(make([]byte, 15))
+	assert.Error(t, err)
+
+	_, err = NewMnemonic(make([]byte, 17))
+	assert.Error(t, err)
+
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+	assert.ErrorContains(t, err, "12, 15, 18, 21 or 24 words")
+
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+	assert.ErrorContains(t, err, "invalid checksum")
+
+	_, err = EntropyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon qwerty about")
+	assert.ErrorContains(t, err, "word 11 isn't in the wordlist")
+	assert.NotContains(t, err.Error(), "qwerty")
+
+	_, err = EntropyFromMnemonic("Abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about")
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package amnesia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// Contribution holds the shares a ceremony participant decrypted with their
+// answers. It's encrypted to the coordinator so the answers never leave the
+// participant's machine and the shares are only readable by the coordinator.
+type Contribution struct {
+	// Fingerprint identifies the share set the shares belong to
+	Fingerprint string          `json:"fingerprint"`
+	Shares      []*TrusteeShare `json:"shares"`
+}
+
+// Wipe zeroes the shares
+func (c *Contribution) Wipe() {
+	for _, share := range c.Shares {
+		share.Wipe()
+	}
+}
+
+// Contribute decrypts the shares for the given answers, which must all belong
+// to one share set, and encrypts them to the coordinator's age recipient. Wrong
+// answers can't be detected until the contributions are combined.
+func (s *Sealer) Contribute(ctx context.Context, sealedSecret *SealedSecret, answers Answers, recipient string) ([]byte, error) {
+	var set *ShareSet
+
+	for id, answer := range answers {
+		if len(answer) == 0 {
+			continue
+		}
+
+		found, _, err := sealedSecret.findShare(id)
+		if err != nil {
+			return nil, err
+		}
+		if set != nil && found != set {
+			return nil, fmt.Errorf("answers must all belong to one share set")
+		}
+		set = found
+	}
+	if set == nil {
+		return nil, fmt.Errorf("no answers given")
+	}
+
+	params, err := sealedSecret.shareSetKDF(set)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ids         []int
+		inputs      []kdfInput
+		ciphertexts [][]byte
+	)
+
+	for _, share := range set.Shares {
+		answer := answers[share.ID]
+		if len(answer) == 0 {
+			continue
+		}
+		if share.IsTrustee() || share.Exported {
+			return nil, fmt.Errorf("share %d can't be answered", share.ID)
+		}
+
+		salt, err := encoding.DecodeString(share.Salt)
+		if err != nil {
+			return nil, err
+		}
+
+		ciphertext, err := encoding.DecodeString(share.Share)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, share.ID)
+		inputs = append(inputs, kdfInput{password: answer, salt: salt})
+		ciphertexts = append(ciphertexts, ciphertext)
+	}
+
+	keys, err := s.deriveKeys(ctx, params, inputs)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeAll(keys)
+
+	contribution := Contribution{Fingerprint: set.Fingerprint()}
+	defer contribution.Wipe()
+
+	for i, ciphertext := range ciphertexts {
+		decryptedShare, err := decryptShare(ciphertext, keys[i])
+		if err != nil {
+			return nil, err
+		}
+
+		contribution.Shares = append(contribution.Shares, &TrusteeShare{ID: ids[i], Share: decryptedShare})
+	}
+
+	plaintext, err := json.Marshal(contribution)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(plaintext)
+
+	return encryptArmored(plaintext, recipient)
+}
+
+// encryptArmored encrypts data to a single age recipient with ASCII armor
+func encryptArmored(data []byte, recipient string) ([]byte, error) {
+	encrypted, err := encryptAge(data, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	aw := armor.NewWriter(&buf)
+	if _, err := aw.Write(encrypted); err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// OpenContributions decrypts contributions with the coordinator's identities
+// and checks they belong to the sealed secret. The returned shares can be
+// given with WithTrusteeShares, and should be wiped after.
+func OpenContributions(sealedSecret *SealedSecret, contributions [][]byte, identities ...age.Identity) ([]*TrusteeShare, error) {
+	var shares []*TrusteeShare
+
+	sets := sealedSecret.shareSets()
+
+	fail := func(err error) ([]*TrusteeShare, error) {
+		for _, share := range shares {
+			share.Wipe()
+		}
+		return nil, err
+	}
+
+	for i, data := range contributions {
+		contribution, err := openContribution(data, identities)
+		if err != nil {
+			return fail(fmt.Errorf("contribution %d: %w", i+1, err))
+		}
+		shares = append(shares, contribution.Shares...)
+
+		idx := slices.IndexFunc(sets, func(set *ShareSet) bool {
+			return set.Fingerprint() == contribution.Fingerprint
+		})
+		if idx < 0 {
+			return fail(fmt.Errorf("contribution %d doesn't belong to this sealed file", i+1))
+		}
+
+		for _, share := range contribution.Shares {
+			if !slices.ContainsFunc(sets[idx].Shares, func(s Share) bool {
+				return s.ID == share.ID
+			}) {
+				return fail(fmt.Errorf("contribution %d has unknown share %d", i+1, share.ID))
+			}
+		}
+	}
+
+	return shares, nil
+}
+
+func openContribution(data []byte, identities []age.Identity) (*Contribution, error) {
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(plaintext)
+
+	var contribution Contribution
+	if err := json.Unmarshal(plaintext, &contribution); err != nil {
+		return nil, fmt.Errorf("error parsing contribution: %w", err)
+	}
+
+	return &contribution, nil
+}
@@ -0,0 +1,475 @@
+package amnesia_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/amnesiatest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "regenerate the known-answer test corpus")
+
+const corpusDir = "amnesiatest/testdata"
+
+var corpusKDFParams = amnesia.KDFParams{
+	Time:    1,
+	Memory:  64,
+	Threads: 1,
+}
+
+type corpus struct {
+	files   map[string][]byte
+	vectors []amnesiatest.Vector
+}
+
+func (c *corpus) seal(t *testing.T, file string, seed byte, secret []byte, questions amnesia.Questions, threshold int, opts ...amnesia.Option) []byte {
+	t.Helper()
+
+	opts = append([]amnesia.Option{
+		amnesia.WithRand(rand.NewChaCha8([32]byte{seed})),
+		amnesia.WithClock(func() time.Time {
+			return time.Date(2025, 7, 16, 23, 55, 59, 0, time.UTC)
+		}),
+	}, opts...)
+
+	sealed, err := amnesia.NewSealer(opts...).Seal(context.Background(), secret, questions, threshold)
+	require.NoError(t, err)
+
+	c.files[file] = sealed
+	return sealed
+}
+
+// fixture reads a file which is kept in the corpus rather than generated
+func (c *corpus) fixture(t *testing.T, file string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(corpusDir, file))
+	require.NoError(t, err)
+
+	c.files[file] = data
+	return data
+}
+
+// addSlot unlocks a sealed file with the answers and adds a slot to it
+func (c *corpus) addSlot(t *testing.T, file string, seed byte, sealed []byte, a amnesia.Answers, spec amnesia.SlotSpec) []byte {
+	t.Helper()
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	require.NoError(t, err)
+
+	key, err := amnesia.DecryptKey(context.Background(), sealedSecret, a)
+	require.NoError(t, err)
+	defer key.Destroy()
+
+	withSlot, err := amnesia.NewSealer(
+		amnesia.WithRand(rand.NewChaCha8([32]byte{seed})),
+		amnesia.WithKDFParams(corpusKDFParams),
+	).AddSlot(context.Background(), sealed, key.Bytes(), spec)
+	require.NoError(t, err)
+
+	// Indent it like sealed files, so the corpus is consistent
+	var indented bytes.Buffer
+	require.NoError(t, json.Indent(&indented, withSlot, "", "  "))
+
+	c.files[file] = indented.Bytes()
+	return indented.Bytes()
+}
+
+// tamper decodes a sealed file, modifies it and stores it under a new name
+func (c *corpus) tamper(t *testing.T, file string, sealed []byte, fn func(*amnesia.SealedSecret)) {
+	t.Helper()
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	require.NoError(t, err)
+
+	fn(sealedSecret)
+
+	tampered, err := json.MarshalIndent(sealedSecret, "", "  ")
+	require.NoError(t, err)
+
+	c.files[file] = tampered
+}
+
+func (c *corpus) vector(v amnesiatest.Vector) {
+	c.vectors = append(c.vectors, v)
+}
+
+func answers(kv ...any) map[int]string {
+	a := make(map[int]string)
+	for i := 0; i < len(kv); i += 2 {
+		a[kv[i].(int)] = kv[i+1].(string)
+	}
+	return a
+}
+
+func flipByte(buf []byte, idx int) []byte {
+	buf = append([]byte(nil), buf...)
+	buf[idx] ^= 0x01
+	return buf
+}
+
+func generateCorpus(t *testing.T) *corpus {
+	c := &corpus{files: make(map[string][]byte)}
+
+	basicQuestions := amnesia.NewQuestions()
+	basicQuestions.Set(0, amnesia.Question{Question: "What's your favourite animal?", Answer: []byte("cat")})
+	basicQuestions.Set(1, amnesia.Question{Question: "What's your favourite food?", Answer: []byte("pizza")})
+	basicQuestions.Set(2, amnesia.Question{Question: "What's your favourite colour?", Answer: []byte("green")})
+
+	plaintext := []byte("zed > vim")
+
+	// Sealed by the original version 1 Seal, which used the default KDF
+	// parameters and didn't record the threshold. It wasn't deterministic, so
+	// the file is kept rather than generated.
+	v1 := c.fixture(t, "v1/basic.json")
+
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/all-answers",
+		Description: "All answers given",
+		File:        "v1/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza", 2, "green")},
+		Plaintext:   plaintext,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/threshold-answers",
+		Description: "Exactly threshold answers given",
+		File:        "v1/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 2, "green")},
+		Plaintext:   plaintext,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/wrong-answer",
+		Description: "One of threshold answers is wrong",
+		File:        "v1/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pasta")},
+		Error:       true,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/too-few-answers",
+		Description: "Fewer than threshold answers given",
+		File:        "v1/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(1, "pizza")},
+		Error:       true,
+	})
+
+	c.tamper(t, "v1/tampered-encrypted.json", v1, func(s *amnesia.SealedSecret) {
+		s.Encrypted = flipByte(s.Encrypted, len(s.Encrypted)-1)
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/tampered-encrypted",
+		Description: "Encrypted data modified",
+		File:        "v1/tampered-encrypted.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza", 2, "green")},
+		Error:       true,
+	})
+
+	c.tamper(t, "v1/tampered-share.json", v1, func(s *amnesia.SealedSecret) {
+		share, _ := base64.StdEncoding.DecodeString(s.Shares[0].Share)
+		s.Shares[0].Share = base64.StdEncoding.EncodeToString(flipByte(share, len(share)-2))
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/tampered-share",
+		Description: "Encrypted share modified",
+		File:        "v1/tampered-share.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza")},
+		Error:       true,
+	})
+
+	c.files["v1/truncated.json"] = v1[:len(v1)/2]
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/truncated",
+		Description: "Sealed file truncated",
+		File:        "v1/truncated.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza", 2, "green")},
+		Error:       true,
+	})
+
+	c.tamper(t, "v1/unknown-version.json", v1, func(s *amnesia.SealedSecret) {
+		s.Version = "0"
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v1/unknown-version",
+		Description: "Unknown format version",
+		File:        "v1/unknown-version.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza", 2, "green")},
+		Error:       true,
+	})
+
+	largeQuestions := amnesia.NewQuestions()
+	largeQuestions.Set(0, amnesia.Question{Question: "Where were you born?", Answer: []byte("東京")})
+	largeQuestions.Set(1, amnesia.Question{Question: "What was your first car?", Answer: []byte("Citroën 2CV")})
+	largeQuestions.Set(2, amnesia.Question{Question: "What's your favourite drink?", Answer: []byte("café au lait")})
+	largeQuestions.Set(5, amnesia.Question{Question: "What was your first pet called?", Answer: []byte("Mr. Whiskers")})
+	largeQuestions.Set(9, amnesia.Question{Question: "What's your lucky number?", Answer: []byte("7")})
+
+	binary := make([]byte, 256)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+
+	v2 := c.seal(t, "v2/basic.json", 2, binary, largeQuestions, 3, amnesia.WithKDFParams(corpusKDFParams))
+
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/all-answers",
+		Description: "All answers given, including non-ASCII and non-contiguous IDs",
+		File:        "v2/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "東京", 1, "Citroën 2CV", 2, "café au lait", 5, "Mr. Whiskers", 9, "7")},
+		Plaintext:   binary,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/blank-answers",
+		Description: "Blank answers are skipped",
+		File:        "v2/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "", 1, "Citroën 2CV", 2, "", 5, "Mr. Whiskers", 9, "7")},
+		Plaintext:   binary,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/case-sensitive",
+		Description: "Answers are used verbatim",
+		File:        "v2/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(1, "citroën 2cv", 5, "Mr. Whiskers", 9, "7")},
+		Error:       true,
+	})
+
+	c.seal(t, "v2/empty.json", 3, []byte{}, basicQuestions, 3, amnesia.WithKDFParams(corpusKDFParams))
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/empty-plaintext",
+		Description: "Empty secret",
+		File:        "v2/empty.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza", 2, "green")},
+		Plaintext:   []byte{},
+	})
+
+	c.tamper(t, "v2/tampered-kdf.json", v2, func(s *amnesia.SealedSecret) {
+		s.KDF.Time++
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/tampered-kdf",
+		Description: "KDF parameters modified",
+		File:        "v2/tampered-kdf.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "東京", 1, "Citroën 2CV", 2, "café au lait")},
+		Error:       true,
+	})
+
+	c.tamper(t, "v2/missing-kdf.json", v2, func(s *amnesia.SealedSecret) {
+		s.KDF = nil
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/missing-kdf",
+		Description: "KDF parameters removed",
+		File:        "v2/missing-kdf.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "東京", 1, "Citroën 2CV", 2, "café au lait")},
+		Error:       true,
+	})
+
+	c.tamper(t, "v2/swapped-shares.json", v2, func(s *amnesia.SealedSecret) {
+		s.Shares[0].Share, s.Shares[1].Share = s.Shares[1].Share, s.Shares[0].Share
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/swapped-shares",
+		Description: "Encrypted shares swapped between questions",
+		File:        "v2/swapped-shares.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "東京", 1, "Citroën 2CV", 2, "café au lait")},
+		Error:       true,
+	})
+
+	c.tamper(t, "v2/truncated-encrypted.json", v2, func(s *amnesia.SealedSecret) {
+		s.Encrypted = s.Encrypted[:8]
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/truncated-encrypted",
+		Description: "Encrypted data shorter than a nonce",
+		File:        "v2/truncated-encrypted.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "東京", 1, "Citroën 2CV", 2, "café au lait")},
+		Error:       true,
+	})
+
+	c.tamper(t, "v2/truncated-share.json", v2, func(s *amnesia.SealedSecret) {
+		share, _ := base64.StdEncoding.DecodeString(s.Shares[0].Share)
+		s.Shares[0].Share = base64.StdEncoding.EncodeToString(share[:8])
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/truncated-share",
+		Description: "Encrypted share shorter than an IV",
+		File:        "v2/truncated-share.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "東京", 1, "Citroën 2CV", 2, "café au lait")},
+		Error:       true,
+	})
+
+	keyfile := []byte("contents of a file on a usb stick")
+
+	c.seal(t, "v2/keyfile.json", 4, plaintext, basicQuestions, 2, amnesia.WithKDFParams(corpusKDFParams), amnesia.WithKeyfile(keyfile))
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/keyfile",
+		Description: "Keyfile required in addition to answers",
+		File:        "v2/keyfile.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza"), Keyfile: keyfile},
+		Plaintext:   plaintext,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/keyfile-missing",
+		Description: "Keyfile required but not given",
+		File:        "v2/keyfile.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza", 2, "green")},
+		Error:       true,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/keyfile-incorrect",
+		Description: "Incorrect keyfile given",
+		File:        "v2/keyfile.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza"), Keyfile: []byte("contents of another file")},
+		Error:       true,
+	})
+
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about\n"
+
+	c.seal(t, "v2/bip39.json", 5, []byte(mnemonic), basicQuestions, 2, amnesia.WithKDFParams(corpusKDFParams), amnesia.WithBIP39())
+	c.vector(amnesiatest.Vector{
+		Name:        "v2/bip39",
+		Description: "BIP-39 mnemonic sealed as its entropy and rebuilt when unsealed",
+		File:        "v2/bip39.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza")},
+		Plaintext:   []byte(mnemonic),
+	})
+
+	basicAnswers := amnesia.NewAnswers()
+	basicAnswers.Set(0, []byte("cat"))
+	basicAnswers.Set(1, []byte("pizza"))
+	basicAnswers.Set(2, []byte("green"))
+
+	passphrase := []byte("correct horse battery staple")
+
+	v3 := c.seal(t, "v3/basic.json", 5, plaintext, basicQuestions, 2, amnesia.WithKDFParams(corpusKDFParams), amnesia.WithFormatVersion(amnesia.FormatV3))
+	v3 = c.addSlot(t, "v3/basic.json", 6, v3, basicAnswers, amnesia.SlotSpec{
+		Type:       amnesia.SlotPassphrase,
+		Label:      "lawyer",
+		Passphrase: passphrase,
+	})
+	v3 = c.addSlot(t, "v3/basic.json", 7, v3, basicAnswers, amnesia.SlotSpec{
+		Type:    amnesia.SlotKeyfile,
+		Keyfile: keyfile,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/questions",
+		Description: "Questions slot unlocked with threshold answers",
+		File:        "v3/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 2, "green")},
+		Plaintext:   plaintext,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/passphrase",
+		Description: "Passphrase slot unlocked without answers",
+		File:        "v3/basic.json",
+		Input:       amnesiatest.Input{Passphrase: passphrase},
+		Plaintext:   plaintext,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/keyfile",
+		Description: "Keyfile slot unlocked without answers",
+		File:        "v3/basic.json",
+		Input:       amnesiatest.Input{Keyfile: keyfile},
+		Plaintext:   plaintext,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/wrong-passphrase",
+		Description: "Incorrect passphrase and too few answers",
+		File:        "v3/basic.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat"), Passphrase: []byte("incorrect horse")},
+		Error:       true,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/no-credentials",
+		Description: "No credentials for any slot",
+		File:        "v3/basic.json",
+		Input:       amnesiatest.Input{},
+		Error:       true,
+	})
+
+	c.tamper(t, "v3/tampered-wrapped-key.json", v3, func(s *amnesia.SealedSecret) {
+		s.Slots[1].WrappedKey = flipByte(s.Slots[1].WrappedKey, 20)
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/tampered-wrapped-key",
+		Description: "Wrapped key of a slot modified",
+		File:        "v3/tampered-wrapped-key.json",
+		Input:       amnesiatest.Input{Passphrase: passphrase},
+		Error:       true,
+	})
+
+	c.addSlot(t, "v3/upgraded.json", 8, c.files["v2/empty.json"], basicAnswers, amnesia.SlotSpec{
+		Type:       amnesia.SlotPassphrase,
+		Passphrase: passphrase,
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/upgraded-questions",
+		Description: "Version 2 share set kept as the first slot after upgrading",
+		File:        "v3/upgraded.json",
+		Input:       amnesiatest.Input{Answers: answers(0, "cat", 1, "pizza", 2, "green")},
+		Plaintext:   []byte{},
+	})
+	c.vector(amnesiatest.Vector{
+		Name:        "v3/upgraded-passphrase",
+		Description: "Slot added when upgrading from version 2",
+		File:        "v3/upgraded.json",
+		Input:       amnesiatest.Input{Passphrase: passphrase},
+		Plaintext:   []byte{},
+	})
+
+	return c
+}
+
+func TestConformance(t *testing.T) {
+	c := generateCorpus(t)
+
+	vectors, err := json.MarshalIndent(c.vectors, "", "  ")
+	require.NoError(t, err)
+	c.files["vectors.json"] = vectors
+
+	for name, data := range c.files {
+		path := filepath.Join(corpusDir, name)
+
+		if *update {
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+			continue
+		}
+
+		existing, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(existing), string(data), "%s changed, run go test -update if this is intended", name)
+	}
+
+	amnesiatest.Run(t, amnesiatest.UnsealFunc(func(ctx context.Context, sealed []byte, input amnesiatest.Input) ([]byte, error) {
+		a := amnesia.NewAnswers()
+		for id, answer := range input.Answers {
+			a.Set(id, []byte(answer))
+		}
+
+		var opts []amnesia.Option
+		if input.Keyfile != nil {
+			opts = append(opts, amnesia.WithKeyfile(input.Keyfile))
+		}
+		if input.Passphrase != nil {
+			opts = append(opts, amnesia.WithPassphrase(input.Passphrase))
+		}
+
+		unsealed, err := amnesia.Unseal(ctx, sealed, a, opts...)
+		if err != nil {
+			return nil, err
+		}
+		defer unsealed.Destroy()
+
+		return bytes.Clone(unsealed.Bytes()), nil
+	}))
+}
@@ -0,0 +1,33 @@
+// Package derive deterministically derives passwords and keys from a sealed
+// master secret, so they can be regenerated from the same answers instead of
+// being stored.
+package derive
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// stream returns an HKDF-SHA256 stream over the master secret. The purpose
+// and fields are length-prefixed into the info so that different inputs can
+// never produce the same info.
+func stream(master []byte, purpose string, fields ...[]byte) io.Reader {
+	info := appendField(nil, []byte("amnesia derive "+purpose))
+	for _, field := range fields {
+		info = appendField(info, field)
+	}
+
+	return hkdf.New(sha256.New, master, nil, info)
+}
+
+func appendField(b, field []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(field)))
+	return append(b, field...)
+}
+
+func uint32Field(v int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(v))
+}
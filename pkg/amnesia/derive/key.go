@@ -0,0 +1,137 @@
+package derive
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"golang.org/x/crypto/ssh"
+)
+
+type Purpose string
+
+const (
+	PurposeAge        Purpose = "age"
+	PurposeSSHEd25519 Purpose = "ssh-ed25519"
+	PurposeEd25519    Purpose = "ed25519"
+	PurposeX25519     Purpose = "x25519"
+	PurposeRaw        Purpose = "raw"
+)
+
+// Key is a derived key in the usual text encoding for its purpose
+type Key struct {
+	Purpose Purpose
+	// Public is empty for raw keys
+	Public string
+	// Private ends with a newline so it can be written to a file as is
+	Private []byte
+}
+
+// Wipe zeroes the private key
+func (k *Key) Wipe() {
+	secmem.Wipe(k.Private)
+}
+
+// DeriveKey derives a key for the purpose from the master secret. Each purpose
+// and label gives an unrelated key, and the same master secret always gives
+// the same key.
+//
+// age identities are printed as AGE-SECRET-KEY-1..., SSH keys in OpenSSH
+// format, ed25519 and x25519 keys as PKCS #8 and PKIX PEM, and raw keys as
+// hex.
+func DeriveKey(master []byte, purpose Purpose, label string) (*Key, error) {
+	seed := make([]byte, 32)
+	defer secmem.Wipe(seed)
+
+	r := stream(master, "key v1", []byte(purpose), []byte(label))
+	if _, err := io.ReadFull(r, seed); err != nil {
+		return nil, err
+	}
+
+	switch purpose {
+	case PurposeAge:
+		return ageKey(seed)
+	case PurposeSSHEd25519:
+		return sshKey(seed, label)
+	case PurposeEd25519:
+		return pemKey(purpose, ed25519.NewKeyFromSeed(seed))
+	case PurposeX25519:
+		private, err := ecdh.X25519().NewPrivateKey(seed)
+		if err != nil {
+			return nil, err
+		}
+		return pemKey(purpose, private)
+	case PurposeRaw:
+		return &Key{Purpose: purpose, Private: append(hex.AppendEncode(nil, seed), '\n')}, nil
+	default:
+		return nil, fmt.Errorf("unknown purpose: %s", purpose)
+	}
+}
+
+func ageKey(seed []byte) (*Key, error) {
+	encoded := strings.ToUpper(bech32Encode("age-secret-key-", seed))
+
+	identity, err := age.ParseX25519Identity(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Purpose: PurposeAge,
+		Public:  identity.Recipient().String(),
+		Private: []byte(encoded + "\n"),
+	}, nil
+}
+
+func sshKey(seed []byte, comment string) (*Key, error) {
+	private := ed25519.NewKeyFromSeed(seed)
+	defer secmem.Wipe(private)
+
+	public, err := ssh.NewPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	authorized := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(public)), "\n")
+	if comment != "" {
+		authorized += " " + comment
+	}
+
+	return &Key{
+		Purpose: PurposeSSHEd25519,
+		Public:  authorized,
+		Private: pem.EncodeToMemory(block),
+	}, nil
+}
+
+func pemKey(purpose Purpose, private interface{ Public() crypto.PublicKey }) (*Key, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(der)
+
+	public, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Purpose: purpose,
+		Public:  strings.TrimSuffix(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})), "\n"),
+		Private: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package derive
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func mustSeed(t *testing.T, purpose Purpose, label string) []byte {
+	seed := make([]byte, 32)
+	_, err := io.ReadFull(stream(testMaster, "key v1", []byte(purpose), []byte(label)), seed)
+	assert.NoError(t, err)
+	return seed
+}
+
+func TestDeriveKey(t *testing.T) {
+	t.Run("Age", func(t *testing.T) {
+		key, err := DeriveKey(testMaster, PurposeAge, "work")
+		assert.NoError(t, err)
+
+		identity, err := age.ParseX25519Identity(strings.TrimSpace(string(key.Private)))
+		assert.NoError(t, err)
+		assert.Equal(t, identity.Recipient().String(), key.Public)
+
+		// The recipient encoding must match age's
+		private, err := ecdh.X25519().NewPrivateKey(mustSeed(t, PurposeAge, "work"))
+		assert.NoError(t, err)
+		assert.Equal(t, key.Public, bech32Encode("age", private.PublicKey().Bytes()))
+	})
+
+	t.Run("SSH", func(t *testing.T) {
+		key, err := DeriveKey(testMaster, PurposeSSHEd25519, "work")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(key.Public, "ssh-ed25519 "))
+		assert.True(t, strings.HasSuffix(key.Public, " work"))
+
+		signer, err := ssh.ParsePrivateKey(key.Private)
+		assert.NoError(t, err)
+
+		public, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.Public))
+		assert.NoError(t, err)
+		assert.Equal(t, public.Marshal(), signer.PublicKey().Marshal())
+	})
+
+	t.Run("Ed25519", func(t *testing.T) {
+		key, err := DeriveKey(testMaster, PurposeEd25519, "work")
+		assert.NoError(t, err)
+
+		block, _ := pem.Decode(key.Private)
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		assert.NoError(t, err)
+		assert.IsType(t, ed25519.PrivateKey{}, private)
+
+		block, _ = pem.Decode([]byte(key.Public))
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		assert.NoError(t, err)
+		assert.Equal(t, private.(ed25519.PrivateKey).Public(), public)
+	})
+
+	t.Run("X25519", func(t *testing.T) {
+		key, err := DeriveKey(testMaster, PurposeX25519, "work")
+		assert.NoError(t, err)
+
+		block, _ := pem.Decode(key.Private)
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		assert.NoError(t, err)
+		assert.IsType(t, &ecdh.PrivateKey{}, private)
+	})
+
+	t.Run("Raw", func(t *testing.T) {
+		key, err := DeriveKey(testMaster, PurposeRaw, "work")
+		assert.NoError(t, err)
+		assert.Empty(t, key.Public)
+		assert.Len(t, key.Private, 65)
+	})
+
+	t.Run("Separated", func(t *testing.T) {
+		a, err := DeriveKey(testMaster, PurposeRaw, "work")
+		assert.NoError(t, err)
+		b, err := DeriveKey(testMaster, PurposeRaw, "home")
+		assert.NoError(t, err)
+		c, err := DeriveKey(testMaster, PurposeRaw, "work")
+		assert.NoError(t, err)
+
+		assert.NotEqual(t, a.Private, b.Private)
+		assert.Equal(t, a.Private, c.Private)
+		assert.NotEqual(t, mustSeed(t, PurposeAge, "work"), mustSeed(t, PurposeX25519, "work"))
+	})
+
+	t.Run("KnownAnswer", func(t *testing.T) {
+		// Derived keys must never change between versions
+		key, err := DeriveKey(testMaster, PurposeAge, "work")
+		assert.NoError(t, err)
+		assert.Equal(t, "age1eflqwtauwna53tarq7f8eae0udpcwwqg5jexp4v0pwhg7dgaeumskrt2f8", key.Public)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := DeriveKey(testMaster, "rsa", "work")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,97 @@
+package derive
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cedws/amnesia/pkg/amnesia/generate"
+)
+
+const (
+	DefaultLength  = 24
+	DefaultCounter = 1
+	maxLength      = 1024
+)
+
+// Site holds the parameters for a site's password. None of them are secret,
+// so they can be stored in a site profile file.
+type Site struct {
+	Counter int    `json:"counter"`
+	Length  int    `json:"length"`
+	Charset string `json:"charset"`
+}
+
+// Password derives the password for a site from the master secret. Changing
+// the counter gives an unrelated password, for when one has to be rotated.
+func Password(master []byte, name string, site Site) ([]byte, error) {
+	name = NormalizeSite(name)
+	if name == "" {
+		return nil, fmt.Errorf("site is required")
+	}
+	if site.Counter < 1 {
+		return nil, fmt.Errorf("counter must be positive")
+	}
+	if site.Length < 1 || site.Length > maxLength {
+		return nil, fmt.Errorf("length must be between 1 and %d", maxLength)
+	}
+	if site.Charset == "" {
+		site.Charset = generate.DefaultCharset
+	}
+
+	r := stream(master, "password v1",
+		[]byte(name),
+		uint32Field(site.Counter),
+		uint32Field(site.Length),
+		[]byte(site.Charset),
+	)
+
+	return generate.Generate(r, generate.Spec{
+		Profile: generate.ProfilePassword,
+		Length:  site.Length,
+		Charset: site.Charset,
+	})
+}
+
+// NormalizeSite lowercases and trims a site name so that the same site always
+// gives the same password
+func NormalizeSite(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Sites is a site profile file, recording the parameters of each site's
+// password but never the password itself
+type Sites struct {
+	Sites map[string]Site `json:"sites"`
+}
+
+func ParseSites(data []byte) (*Sites, error) {
+	var sites Sites
+
+	if err := json.Unmarshal(data, &sites); err != nil {
+		return nil, fmt.Errorf("error parsing site profiles: %w", err)
+	}
+	if sites.Sites == nil {
+		sites.Sites = make(map[string]Site)
+	}
+
+	return &sites, nil
+}
+
+func (s *Sites) Encode() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Get returns the parameters recorded for a site
+func (s *Sites) Get(name string) (Site, bool) {
+	site, ok := s.Sites[NormalizeSite(name)]
+	return site, ok
+}
+
+// Set records the parameters for a site
+func (s *Sites) Set(name string, site Site) {
+	if s.Sites == nil {
+		s.Sites = make(map[string]Site)
+	}
+	s.Sites[NormalizeSite(name)] = site
+}
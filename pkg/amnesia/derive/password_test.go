@@ -0,0 +1,85 @@
+package derive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cedws/amnesia/pkg/amnesia/generate"
+	"github.com/stretchr/testify/assert"
+)
+
+var testMaster = []byte("correct horse battery staple")
+
+func TestPassword(t *testing.T) {
+	site := Site{Counter: 1, Length: 24}
+
+	password, err := Password(testMaster, "example.com", site)
+	assert.NoError(t, err)
+	assert.Len(t, password, 24)
+
+	t.Run("KnownAnswer", func(t *testing.T) {
+		// Derived passwords must never change between versions
+		assert.Equal(t, "_;_jMf2tKMi3XK+}bm-SKT<5", string(password))
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		again, err := Password(testMaster, " Example.COM ", site)
+		assert.NoError(t, err)
+		assert.Equal(t, password, again)
+	})
+
+	t.Run("Separated", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			site Site
+		}{
+			{"example.org", site},
+			{"example.com", Site{Counter: 2, Length: 24}},
+			{"example.com", Site{Counter: 1, Length: 25}},
+			{"example.com", Site{Counter: 1, Length: 24, Charset: generate.DefaultCharset + " "}},
+		} {
+			other, err := Password(testMaster, tc.name, tc.site)
+			assert.NoError(t, err)
+			assert.NotEqual(t, string(password), string(other)[:24])
+		}
+
+		other, err := Password([]byte("another master"), "example.com", site)
+		assert.NoError(t, err)
+		assert.NotEqual(t, password, other)
+	})
+
+	t.Run("Charset", func(t *testing.T) {
+		pin, err := Password(testMaster, "bank", Site{Counter: 1, Length: 6, Charset: generate.Digits})
+		assert.NoError(t, err)
+		assert.Len(t, pin, 6)
+		assert.Empty(t, strings.Trim(string(pin), generate.Digits))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := Password(testMaster, "", site)
+		assert.Error(t, err)
+
+		_, err = Password(testMaster, "example.com", Site{Counter: 0, Length: 24})
+		assert.Error(t, err)
+
+		_, err = Password(testMaster, "example.com", Site{Counter: 1, Length: 0})
+		assert.Error(t, err)
+	})
+}
+
+func TestSites(t *testing.T) {
+	sites, err := ParseSites([]byte(`{}`))
+	assert.NoError(t, err)
+
+	sites.Set("Example.com", Site{Counter: 2, Length: 16, Charset: generate.Lower})
+
+	encoded, err := sites.Encode()
+	assert.NoError(t, err)
+
+	parsed, err := ParseSites(encoded)
+	assert.NoError(t, err)
+
+	site, ok := parsed.Get("example.com")
+	assert.True(t, ok)
+	assert.Equal(t, Site{Counter: 2, Length: 16, Charset: generate.Lower}, site)
+}
@@ -0,0 +1,96 @@
+package amnesia
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cedws/amnesia/pkg/amnesia/amnesiatest"
+)
+
+func addCorpusSeeds(f *testing.F, fn func(sealed []byte, v amnesiatest.Vector)) {
+	vectors, err := amnesiatest.Vectors()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		sealed, err := v.Sealed()
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		fn(sealed, v)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addCorpusSeeds(f, func(sealed []byte, _ amnesiatest.Vector) {
+		f.Add(sealed)
+	})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sealedSecret, err := Decode(data)
+		if err != nil {
+			return
+		}
+
+		encoded, err := Encode(sealedSecret)
+		if err != nil {
+			t.Fatalf("error encoding decoded secret: %v", err)
+		}
+
+		if _, err := Decode(encoded); err != nil {
+			t.Fatalf("error decoding encoded secret: %v", err)
+		}
+	})
+}
+
+func FuzzUnseal(f *testing.F) {
+	addCorpusSeeds(f, func(sealed []byte, v amnesiatest.Vector) {
+		f.Add(sealed, []byte(v.Answers[0]), []byte(v.Answers[1]), []byte(v.Answers[2]))
+	})
+
+	f.Fuzz(func(t *testing.T, data, a0, a1, a2 []byte) {
+		sealedSecret, err := Decode(data)
+		if err != nil {
+			return
+		}
+
+		// Keep KDF evaluations cheap so the fuzzer makes progress
+		params, err := sealedSecret.kdfParams()
+		if err != nil || params.Memory > 1024 || params.Time > 2 || len(sealedSecret.Shares) > 8 {
+			t.Skip()
+		}
+
+		answers := NewAnswers()
+		answers.Set(0, a0)
+		answers.Set(1, a1)
+		answers.Set(2, a2)
+
+		unsealed, err := Unseal(t.Context(), data, answers)
+		if err == nil {
+			unsealed.Destroy()
+		}
+	})
+}
+
+func FuzzDecryptShare(f *testing.F) {
+	f.Add([]byte{}, bytes.Repeat([]byte{0}, 32))
+	f.Add(bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 32))
+	f.Add(bytes.Repeat([]byte{3}, 49), bytes.Repeat([]byte{4}, 32))
+
+	f.Fuzz(func(t *testing.T, data, key []byte) {
+		if len(key) < 32 {
+			t.Skip()
+		}
+
+		plaintext, err := decryptShare(data, key)
+		if err != nil {
+			return
+		}
+
+		if len(plaintext) != len(data)-16 {
+			t.Fatalf("plaintext length %d, want %d", len(plaintext), len(data)-16)
+		}
+	})
+}
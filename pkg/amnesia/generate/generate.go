@@ -0,0 +1,208 @@
+// Package generate creates random secrets to be sealed without passing
+// through stdin.
+package generate
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/cedws/amnesia/pkg/amnesia/bip39"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+type Profile string
+
+const (
+	ProfilePassword   Profile = "password"
+	ProfilePassphrase Profile = "passphrase"
+	ProfileBytes      Profile = "bytes"
+	ProfileHex        Profile = "hex"
+	ProfileBase64     Profile = "base64"
+	ProfileBIP39      Profile = "bip39"
+)
+
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+// DefaultCharset is used for passwords if no charset is given
+const DefaultCharset = Lower + Upper + Digits + Symbols
+
+// Spec describes the secret to generate. Fields unused by the profile are
+// ignored.
+type Spec struct {
+	Profile Profile
+	// Length is the number of characters of a password, or bytes for the
+	// bytes, hex and base64 profiles
+	Length  int
+	Charset string
+	// Words is the number of words of a passphrase or BIP-39 mnemonic
+	Words     int
+	Separator string
+}
+
+// Generate creates a random secret. The caller should wipe it after sealing.
+func Generate(rand io.Reader, spec Spec) ([]byte, error) {
+	switch spec.Profile {
+	case ProfilePassword:
+		charset := spec.Charset
+		if charset == "" {
+			charset = DefaultCharset
+		}
+		return password(rand, spec.Length, charset)
+	case ProfilePassphrase:
+		return passphrase(rand, spec.Words, spec.Separator)
+	case ProfileBytes:
+		return randomBytes(rand, spec.Length)
+	case ProfileHex:
+		return encoded(rand, spec.Length, func(b []byte) []byte {
+			return hex.AppendEncode(nil, b)
+		})
+	case ProfileBase64:
+		return encoded(rand, spec.Length, func(b []byte) []byte {
+			return base64.StdEncoding.AppendEncode(nil, b)
+		})
+	case ProfileBIP39:
+		return mnemonic(rand, spec.Words)
+	default:
+		return nil, fmt.Errorf("unknown profile: %s", spec.Profile)
+	}
+}
+
+// Bits returns the entropy of a secret generated with the spec
+func Bits(spec Spec) float64 {
+	switch spec.Profile {
+	case ProfilePassword:
+		charset := spec.Charset
+		if charset == "" {
+			charset = DefaultCharset
+		}
+		return float64(spec.Length) * math.Log2(float64(len([]rune(charset))))
+	case ProfilePassphrase:
+		return float64(spec.Words) * math.Log2(float64(len(bip39.Wordlist())))
+	case ProfileBytes, ProfileHex, ProfileBase64:
+		return float64(spec.Length * 8)
+	case ProfileBIP39:
+		return float64(spec.Words / 3 * 32)
+	default:
+		return 0
+	}
+}
+
+func randomBytes(rand io.Reader, length int) ([]byte, error) {
+	if length < 1 {
+		return nil, fmt.Errorf("length must be positive")
+	}
+
+	b := make([]byte, length)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, fmt.Errorf("error reading random bytes: %w", err)
+	}
+
+	return b, nil
+}
+
+func encoded(rand io.Reader, length int, encode func([]byte) []byte) ([]byte, error) {
+	b, err := randomBytes(rand, length)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(b)
+
+	return encode(b), nil
+}
+
+// uniform returns a uniformly random index below n
+func uniform(rand io.Reader, n int) (int, error) {
+	if n < 1 || n > 1<<16 {
+		return 0, fmt.Errorf("invalid range %d", n)
+	}
+
+	// Reject values past the largest multiple of n to avoid modulo bias
+	limit := 1<<16 - 1<<16%n
+
+	var b [2]byte
+	for {
+		if _, err := io.ReadFull(rand, b[:]); err != nil {
+			return 0, fmt.Errorf("error reading random bytes: %w", err)
+		}
+
+		if v := int(b[0])<<8 | int(b[1]); v < limit {
+			return v % n, nil
+		}
+	}
+}
+
+func password(rand io.Reader, length int, charset string) ([]byte, error) {
+	if length < 1 {
+		return nil, fmt.Errorf("length must be positive")
+	}
+
+	chars := []rune(charset)
+	seen := make(map[rune]bool, len(chars))
+	for _, c := range chars {
+		if seen[c] {
+			return nil, fmt.Errorf("charset contains %q more than once", c)
+		}
+		seen[c] = true
+	}
+	if len(chars) < 2 {
+		return nil, fmt.Errorf("charset must have at least 2 characters")
+	}
+
+	var b strings.Builder
+	for range length {
+		idx, err := uniform(rand, len(chars))
+		if err != nil {
+			return nil, err
+		}
+		b.WriteRune(chars[idx])
+	}
+
+	return []byte(b.String()), nil
+}
+
+func passphrase(rand io.Reader, words int, separator string) ([]byte, error) {
+	if words < 1 {
+		return nil, fmt.Errorf("words must be positive")
+	}
+
+	wordlist := bip39.Wordlist()
+
+	var chosen []string
+	for range words {
+		idx, err := uniform(rand, len(wordlist))
+		if err != nil {
+			return nil, err
+		}
+		chosen = append(chosen, wordlist[idx])
+	}
+
+	return []byte(strings.Join(chosen, separator)), nil
+}
+
+func mnemonic(rand io.Reader, words int) ([]byte, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return nil, fmt.Errorf("BIP-39 mnemonics must have 12, 15, 18, 21 or 24 words")
+	}
+
+	entropy, err := randomBytes(rand, words/3*4)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(entropy)
+
+	m, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(m), nil
+}
@@ -0,0 +1,87 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/cedws/amnesia/pkg/amnesia/bip39"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRand() *rand.ChaCha8 {
+	return rand.NewChaCha8([32]byte{1})
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("Password", func(t *testing.T) {
+		secret, err := Generate(testRand(), Spec{Profile: ProfilePassword, Length: 64, Charset: Digits})
+		assert.NoError(t, err)
+		assert.Len(t, secret, 64)
+		assert.Empty(t, strings.Trim(string(secret), Digits))
+
+		secret, err = Generate(testRand(), Spec{Profile: ProfilePassword, Length: 20, Charset: "äöü"})
+		assert.NoError(t, err)
+		assert.Equal(t, 20, len([]rune(string(secret))))
+
+		_, err = Generate(testRand(), Spec{Profile: ProfilePassword, Length: 20, Charset: "aa"})
+		assert.Error(t, err)
+	})
+
+	t.Run("Passphrase", func(t *testing.T) {
+		secret, err := Generate(testRand(), Spec{Profile: ProfilePassphrase, Words: 6, Separator: "-"})
+		assert.NoError(t, err)
+
+		words := strings.Split(string(secret), "-")
+		assert.Len(t, words, 6)
+		for _, word := range words {
+			assert.Contains(t, bip39.Wordlist(), word)
+		}
+	})
+
+	t.Run("Encoded", func(t *testing.T) {
+		raw, err := Generate(testRand(), Spec{Profile: ProfileBytes, Length: 32})
+		assert.NoError(t, err)
+		assert.Len(t, raw, 32)
+
+		secret, err := Generate(testRand(), Spec{Profile: ProfileHex, Length: 32})
+		assert.NoError(t, err)
+		decoded, err := hex.DecodeString(string(secret))
+		assert.NoError(t, err)
+		assert.Equal(t, raw, decoded)
+
+		secret, err = Generate(testRand(), Spec{Profile: ProfileBase64, Length: 32})
+		assert.NoError(t, err)
+		decoded, err = base64.StdEncoding.DecodeString(string(secret))
+		assert.NoError(t, err)
+		assert.Equal(t, raw, decoded)
+	})
+
+	t.Run("BIP39", func(t *testing.T) {
+		secret, err := Generate(testRand(), Spec{Profile: ProfileBIP39, Words: 12})
+		assert.NoError(t, err)
+		_, err = bip39.EntropyFromMnemonic(string(secret))
+		assert.NoError(t, err)
+
+		_, err = Generate(testRand(), Spec{Profile: ProfileBIP39, Words: 13})
+		assert.Error(t, err)
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		a, err := Generate(testRand(), Spec{Profile: ProfileBytes, Length: 32})
+		assert.NoError(t, err)
+		b, err := Generate(rand.NewChaCha8([32]byte{2}), Spec{Profile: ProfileBytes, Length: 32})
+		assert.NoError(t, err)
+		assert.False(t, bytes.Equal(a, b))
+	})
+}
+
+func TestBits(t *testing.T) {
+	assert.InDelta(t, 128, Bits(Spec{Profile: ProfilePassword, Length: 32, Charset: Digits + "abcdef"}), 0.001)
+	assert.InDelta(t, 66, Bits(Spec{Profile: ProfilePassphrase, Words: 6}), 0.001)
+	assert.InDelta(t, 256, Bits(Spec{Profile: ProfileBase64, Length: 32}), 0.001)
+	assert.InDelta(t, 256, Bits(Spec{Profile: ProfileBIP39, Words: 24}), 0.001)
+}
@@ -0,0 +1,50 @@
+package interactive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cedws/amnesia/pkg/amnesia"
+)
+
+// Contribute asks for answers to some of the sealed secret's questions and
+// returns the decrypted shares encrypted to the coordinator's age recipient
+func Contribute(ctx context.Context, sealed []byte, recipient string, opts ...Option) ([]byte, error) {
+	options := newOptions(opts)
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &sealedSecret.ShareSet
+
+	if sealedSecret.Version == amnesia.FormatV3 {
+		var slots []amnesia.Slot
+		for _, slot := range sealedSecret.Slots {
+			if slot.Type == amnesia.SlotQuestions {
+				slots = append(slots, slot)
+			}
+		}
+		if len(slots) == 0 {
+			return nil, fmt.Errorf("sealed file has no questions slot")
+		}
+
+		slot, err := promptForSlot(ctx, slots)
+		if err != nil {
+			return nil, err
+		}
+		set = &slot.ShareSet
+	}
+
+	answers, _, err := collectAnswers(ctx, set, options, true)
+	if err != nil {
+		return nil, err
+	}
+	defer answers.Wipe()
+
+	return withProgress(ctx, "Deriving keys", func(ctx context.Context, progress amnesia.Option) ([]byte, error) {
+		opts := append(options.amnesiaOpts(), progress)
+		return amnesia.Contribute(ctx, sealedSecret, answers, recipient, opts...)
+	})
+}
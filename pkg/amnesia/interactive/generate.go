@@ -0,0 +1,27 @@
+package interactive
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+// noteEscaper escapes the characters huh renders as markdown in notes
+var noteEscaper = strings.NewReplacer(`\`, `\\`, `_`, `\_`, `*`, `\*`, "`", "\\`")
+
+// ShowSecret shows a generated secret once so it can be memorised. It's
+// cleared from the terminal when dismissed.
+func ShowSecret(ctx context.Context, secret []byte) error {
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title("Memorise this secret").
+				Description(noteEscaper.Replace(string(secret))).
+				Next(true).
+				NextLabel("Done"),
+		),
+	)
+
+	return form.RunWithContext(ctx)
+}
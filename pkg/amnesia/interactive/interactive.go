@@ -2,14 +2,28 @@ package interactive
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
 
+	"filippo.io/age"
 	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/agent"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 	"github.com/charmbracelet/huh"
 )
 
 type options struct {
 	testQuestions bool
+	keyfile       []byte
+	trustees      []string
+	identities    []age.Identity
+	trusteeShares []*amnesia.TrusteeShare
+	bip39         bool
+	agent         *agent.Client
 }
 
 type Option func(*options)
@@ -20,30 +34,132 @@ func WithTestQuestions() Option {
 	}
 }
 
-func DecryptKey(ctx context.Context, secret []byte, _ ...Option) ([]byte, error) {
-	sealedSecret, err := amnesia.Decode(secret)
+// WithKeyfile sets the keyfile contents. When sealing, the keyfile becomes
+// required in addition to answers. When unsealing, the user is prompted for a
+// keyfile path if one is required and this isn't set.
+func WithKeyfile(keyfile []byte) Option {
+	return func(o *options) {
+		o.keyfile = keyfile
+	}
+}
+
+// WithTrustees sets age recipients that are each given a share when sealing
+func WithTrustees(recipients ...string) Option {
+	return func(o *options) {
+		o.trustees = recipients
+	}
+}
+
+// WithIdentities sets age identities used for age slots and trustee shares,
+// so they don't have to be prompted for
+func WithIdentities(identities ...age.Identity) Option {
+	return func(o *options) {
+		o.identities = identities
+	}
+}
+
+// WithTrusteeShares adds shares already decrypted by their trustees or
+// ceremony participants
+func WithTrusteeShares(shares ...*amnesia.TrusteeShare) Option {
+	return func(o *options) {
+		o.trusteeShares = append(o.trusteeShares, shares...)
+	}
+}
+
+// WithBIP39 seals the secret as a BIP-39 mnemonic
+func WithBIP39() Option {
+	return func(o *options) {
+		o.bip39 = true
+	}
+}
+
+// WithAgent remembers unlocked DEKs in the agent, and uses ones it remembers
+// instead of prompting
+func WithAgent(client *agent.Client) Option {
+	return func(o *options) {
+		o.agent = client
+	}
+}
+
+func newOptions(opts []Option) *options {
+	options := &options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options
+}
+
+func (o *options) amnesiaOpts() []amnesia.Option {
+	var opts []amnesia.Option
+
+	if o.keyfile != nil {
+		opts = append(opts, amnesia.WithKeyfile(o.keyfile))
+	}
+	if o.trustees != nil {
+		opts = append(opts, amnesia.WithTrustees(o.trustees...))
+	}
+	if o.identities != nil {
+		opts = append(opts, amnesia.WithIdentities(o.identities...))
+	}
+	if o.bip39 {
+		opts = append(opts, amnesia.WithBIP39())
+	}
+
+	return opts
+}
+
+// resolveKeyfile prompts for a keyfile if the share set requires one and none
+// was given. The returned func wipes a prompted keyfile.
+func (o *options) resolveKeyfile(ctx context.Context, set *amnesia.ShareSet) (func(), error) {
+	if !set.RequiresKeyfile() || o.keyfile != nil {
+		return func() {}, nil
+	}
+
+	keyfile, err := promptForKeyfile(ctx, "This secret requires a keyfile in addition to answers")
 	if err != nil {
 		return nil, err
 	}
+	o.keyfile = keyfile
+
+	return func() { secmem.Wipe(keyfile) }, nil
+}
 
-	answers, err := collectAnswers(ctx, sealedSecret)
+func DecryptKey(ctx context.Context, secret []byte, opts ...Option) (*secmem.Buffer, error) {
+	options := newOptions(opts)
+
+	sealedSecret, err := amnesia.Decode(secret)
 	if err != nil {
 		return nil, err
 	}
 
-	return amnesia.DecryptKey(sealedSecret, answers)
+	if options.agent != nil {
+		return unlockWithAgent(ctx, sealedSecret, options)
+	}
+
+	return unlock(ctx, sealedSecret, options)
 }
 
 func Seal(ctx context.Context, secret []byte, opts ...Option) ([]byte, error) {
-	options := &options{}
-	for _, opt := range opts {
-		opt(options)
+	options := newOptions(opts)
+
+	// Check trustees and the mnemonic before asking for questions
+	for _, recipient := range options.trustees {
+		if _, err := age.ParseRecipients(strings.NewReader(recipient)); err != nil {
+			return nil, fmt.Errorf("invalid trustee %q: %w", recipient, err)
+		}
+	}
+	if options.bip39 {
+		if err := amnesia.ValidateBIP39(secret); err != nil {
+			return nil, err
+		}
 	}
 
 	questions, err := promptForQuestions(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer func() { questions.Wipe() }()
 
 	if options.testQuestions {
 		if err := promptForTestQuestions(ctx, questions); err != nil {
@@ -51,67 +167,346 @@ func Seal(ctx context.Context, secret []byte, opts ...Option) ([]byte, error) {
 		}
 	}
 
-	threshold, err := promptForThreshold(ctx, len(questions))
+	reviewed, err := reviewQuestions(ctx, questions, options.testQuestions)
 	if err != nil {
 		return nil, err
 	}
+	questions = reviewed
 
-	return amnesia.Seal(secret, questions, threshold)
+	threshold, err := promptForThreshold(ctx, len(questions)+len(options.trustees))
+	if err != nil {
+		return nil, err
+	}
+
+	return withProgress(ctx, "Deriving keys", func(ctx context.Context, progress amnesia.Option) ([]byte, error) {
+		return amnesia.Seal(ctx, secret, questions, threshold, append(options.amnesiaOpts(), progress)...)
+	})
 }
 
-func Unseal(ctx context.Context, secret []byte, _ ...Option) ([]byte, error) {
-	sealedSecret, err := amnesia.Decode(secret)
+func Unseal(ctx context.Context, secret []byte, opts ...Option) (*secmem.Buffer, error) {
+	key, err := DecryptKey(ctx, secret, opts...)
 	if err != nil {
 		return nil, err
 	}
+	defer key.Destroy()
 
-	answers, err := collectAnswers(ctx, sealedSecret)
+	return amnesia.UnsealWithKey(secret, key.Bytes())
+}
+
+func Reseal(ctx context.Context, sealed, newSecret []byte, opts ...Option) ([]byte, error) {
+	key, err := DecryptKey(ctx, sealed, opts...)
 	if err != nil {
 		return nil, err
 	}
+	defer key.Destroy()
 
-	return amnesia.Unseal(secret, answers)
+	return amnesia.ResealWithKey(sealed, newSecret, key.Bytes())
 }
 
-func Reseal(ctx context.Context, sealed, newSecret []byte, _ ...Option) ([]byte, error) {
-	sealedSecret, err := amnesia.Decode(sealed)
+// unlockWithAgent uses the DEK the agent remembers for the sealed secret, or
+// unlocks it and gives the DEK to the agent. Problems reaching the agent are
+// only warned about.
+func unlockWithAgent(ctx context.Context, sealedSecret *amnesia.SealedSecret, options *options) (*secmem.Buffer, error) {
+	fingerprint := sealedSecret.Fingerprint()
+
+	key, err := options.agent.Get(fingerprint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	if key != nil {
+		if amnesia.VerifyKey(sealedSecret, key.Bytes()) == nil {
+			return key, nil
+		}
+		key.Destroy()
+	}
+
+	key, err = unlock(ctx, sealedSecret, options)
 	if err != nil {
 		return nil, err
 	}
 
-	answers, err := collectAnswers(ctx, sealedSecret)
+	if err := options.agent.Put(fingerprint, key.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	return key, nil
+}
+
+// unlock recovers the DEK, asking which slot to use if there's more than one
+func unlock(ctx context.Context, sealedSecret *amnesia.SealedSecret, options *options) (*secmem.Buffer, error) {
+	if sealedSecret.Version != amnesia.FormatV3 {
+		return unlockShareSet(ctx, sealedSecret, &sealedSecret.ShareSet, options)
+	}
+
+	slot, err := promptForSlot(ctx, sealedSecret.Slots)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []amnesia.Option{amnesia.WithSlot(slot.ID)}
+
+	switch slot.Type {
+	case amnesia.SlotQuestions:
+		return unlockShareSet(ctx, sealedSecret, &slot.ShareSet, options, opts...)
+	case amnesia.SlotPassphrase:
+		passphrase, err := promptForPassphrase(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer secmem.Wipe(passphrase)
+
+		opts = append(opts, amnesia.WithPassphrase(passphrase))
+	case amnesia.SlotAge:
+		if options.identities == nil {
+			identities, err := promptForIdentities(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			opts = append(opts, amnesia.WithIdentities(identities...))
+		}
+	case amnesia.SlotKeyfile:
+		if options.keyfile == nil {
+			keyfile, err := promptForKeyfile(ctx, "This slot is unlocked by a keyfile")
+			if err != nil {
+				return nil, err
+			}
+			defer secmem.Wipe(keyfile)
+
+			options.keyfile = keyfile
+		}
+	}
+
+	return decryptKey(ctx, sealedSecret, nil, options, opts...)
+}
+
+func unlockShareSet(
+	ctx context.Context,
+	sealedSecret *amnesia.SealedSecret,
+	set *amnesia.ShareSet,
+	options *options,
+	opts ...amnesia.Option,
+) (*secmem.Buffer, error) {
+	wipeKeyfile, err := options.resolveKeyfile(ctx, set)
 	if err != nil {
 		return nil, err
 	}
+	defer wipeKeyfile()
 
-	key, err := amnesia.DecryptKey(sealedSecret, answers)
+	answers, trusteeShares, err := collectAnswers(ctx, set, options, false)
 	if err != nil {
 		return nil, err
 	}
+	defer answers.Wipe()
+	defer wipeTrusteeShares(trusteeShares)
 
-	return amnesia.ResealWithKey(sealed, newSecret, key)
+	opts = append(opts, amnesia.WithTrusteeShares(trusteeShares...))
+
+	return decryptKey(ctx, sealedSecret, answers, options, opts...)
+}
+
+func decryptKey(
+	ctx context.Context,
+	sealedSecret *amnesia.SealedSecret,
+	answers amnesia.Answers,
+	options *options,
+	opts ...amnesia.Option,
+) (*secmem.Buffer, error) {
+	return withProgress(ctx, "Deriving keys", func(ctx context.Context, progress amnesia.Option) (*secmem.Buffer, error) {
+		opts := append(append(options.amnesiaOpts(), opts...), progress)
+		return amnesia.DecryptKey(ctx, sealedSecret, answers, opts...)
+	})
 }
 
-func collectAnswers(ctx context.Context, sealedSecret *amnesia.SealedSecret) (amnesia.Answers, error) {
+// unsealChoice is the selection value for starting the unseal or
+// contribution, distinct from any share ID
+const unsealChoice = -1
+
+// collectAnswers asks for answers and trustee shares until the threshold is
+// reached. Shares given as options or decryptable by given identities are
+// filled in up front. When contributing to a ceremony only questions are
+// offered and a single answer is enough.
+func collectAnswers(
+	ctx context.Context,
+	set *amnesia.ShareSet,
+	options *options,
+	contribute bool,
+) (amnesia.Answers, []*amnesia.TrusteeShare, error) {
+	seen := make(map[int]bool, len(set.Shares))
+	for _, share := range set.Shares {
+		if seen[share.ID] {
+			return nil, nil, fmt.Errorf("duplicate share id %d", share.ID)
+		}
+		seen[share.ID] = true
+	}
+
+	threshold := set.Threshold
+	if threshold == 0 {
+		// Older sealed files don't record the threshold
+		threshold = amnesia.MinQuestions
+	}
+
+	available := 0
+	for _, share := range set.Shares {
+		if !share.Exported && !(contribute && share.IsTrustee()) {
+			available++
+		}
+	}
+	if contribute {
+		// Each participant only answers their own questions
+		threshold = 1
+	}
+	if available < threshold {
+		return nil, nil, fmt.Errorf("only %d of %d required shares available, import exported shares first", available, threshold)
+	}
+
 	answers := amnesia.NewAnswers()
+	trustees := make(map[int]*amnesia.TrusteeShare)
 
-	for _, share := range sealedSecret.Shares {
-		answer, err := promptForAnswer(ctx, share.Question)
-		if err != nil {
-			return nil, err
+	fail := func(err error) (amnesia.Answers, []*amnesia.TrusteeShare, error) {
+		answers.Wipe()
+		wipeTrusteeShares(slices.Collect(maps.Values(trustees)))
+		return nil, nil, err
+	}
+
+	for _, share := range set.Shares {
+		if contribute || share.Exported {
+			continue
+		}
+
+		for _, given := range options.trusteeShares {
+			if given.ID == share.ID {
+				trustees[share.ID] = given
+			}
+		}
+
+		if trustees[share.ID] == nil && share.IsTrustee() && options.identities != nil {
+			decrypted, err := share.DecryptTrustee(options.identities...)
+			if err != nil {
+				return fail(err)
+			}
+			if decrypted != nil {
+				trustees[share.ID] = decrypted
+			}
+		}
+	}
+
+	choice := unsealChoice
+
+	for {
+		if err := promptForSelection(ctx, set, answers, trustees, threshold, contribute, &choice); err != nil {
+			return fail(err)
+		}
+		if choice == unsealChoice {
+			return answers, slices.Collect(maps.Values(trustees)), nil
+		}
+
+		for _, share := range set.Shares {
+			if share.ID != choice {
+				continue
+			}
+
+			if share.IsTrustee() {
+				trusteeShare, err := promptForTrusteeShare(ctx, &share)
+				if err != nil {
+					return fail(err)
+				}
+
+				if previous := trustees[share.ID]; previous != nil {
+					previous.Wipe()
+				}
+				delete(trustees, share.ID)
+
+				if trusteeShare != nil {
+					trustees[share.ID] = trusteeShare
+				}
+				continue
+			}
+
+			answer, err := promptForAnswer(ctx, share.Question, string(answers[share.ID]))
+			if err != nil {
+				return fail(err)
+			}
+
+			if answer == "" {
+				answers.Delete(share.ID)
+			} else {
+				answers.Delete(share.ID)
+				answers.Set(share.ID, []byte(answer))
+			}
 		}
-		if answer == "" {
+	}
+}
+
+func wipeTrusteeShares(shares []*amnesia.TrusteeShare) {
+	for _, share := range shares {
+		share.Wipe()
+	}
+}
+
+func promptForSelection(
+	ctx context.Context,
+	set *amnesia.ShareSet,
+	answers amnesia.Answers,
+	trustees map[int]*amnesia.TrusteeShare,
+	threshold int,
+	contribute bool,
+	choice *int,
+) error {
+	var options []huh.Option[int]
+
+	for _, share := range set.Shares {
+		if share.Exported || (contribute && share.IsTrustee()) {
 			continue
 		}
 
+		mark := "[ ]"
 		if _, ok := answers[share.ID]; ok {
-			return nil, fmt.Errorf("duplicate share id %d", share.ID)
+			mark = "[x]"
+		}
+		if _, ok := trustees[share.ID]; ok {
+			mark = "[x]"
 		}
 
-		answers[share.ID] = answer
+		label := share.Question
+		if share.IsTrustee() {
+			label = fmt.Sprintf("Trustee %s", share.Recipient)
+		}
+
+		options = append(options, huh.NewOption(fmt.Sprintf("%s %s", mark, label), share.ID))
 	}
 
-	return answers, nil
+	given := len(answers) + len(trustees)
+
+	action := "Unseal"
+	progress := fmt.Sprintf("%d/%d answers", given, threshold)
+	if set.Threshold == 0 {
+		progress = fmt.Sprintf("%d answers (threshold unknown, at least %d)", given, threshold)
+	}
+	if contribute {
+		action = "Contribute"
+		progress = fmt.Sprintf("%d answers", given)
+	}
+
+	options = append(options, huh.NewOption(fmt.Sprintf("%s (%s)", action, progress), unsealChoice))
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[int]().
+				Title("Select questions to answer").
+				Description("Pick the questions you're confident about, select one again to change or clear its answer").
+				Options(options...).
+				Value(choice).
+				Validate(func(id int) error {
+					if id == unsealChoice && given < threshold {
+						return fmt.Errorf("%d more answers required", threshold-given)
+					}
+					return nil
+				}),
+		),
+	)
+
+	return form.RunWithContext(ctx)
 }
 
 func promptForQuestions(ctx context.Context) (amnesia.Questions, error) {
@@ -170,7 +565,7 @@ func promptForQuestions(ctx context.Context) (amnesia.Questions, error) {
 
 		questions.Set(len(questions), amnesia.Question{
 			Question: question,
-			Answer:   answer,
+			Answer:   []byte(answer),
 		})
 
 		if len(questions) == amnesia.MaxQuestions {
@@ -181,6 +576,225 @@ func promptForQuestions(ctx context.Context) (amnesia.Questions, error) {
 	return questions, nil
 }
 
+type reviewAction int
+
+const (
+	reviewBack reviewAction = iota
+	reviewEdit
+	reviewReveal
+	reviewMoveUp
+	reviewMoveDown
+	reviewDelete
+)
+
+// reviewDone is the selection value for finishing the review, distinct from
+// any question index
+const reviewDone = -1
+
+type reviewItem struct {
+	amnesia.Question
+	changed bool
+}
+
+// reviewQuestions lets the user edit, delete, reorder and reveal the entered
+// questions before sealing. Changed questions are tested again if test
+// questions are enabled.
+func reviewQuestions(ctx context.Context, questions amnesia.Questions, testQuestions bool) (amnesia.Questions, error) {
+	ids := make([]int, 0, len(questions))
+	for id := range questions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	items := make([]reviewItem, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, reviewItem{Question: questions[id]})
+	}
+
+	choice := reviewDone
+
+	for {
+		if err := promptForReview(ctx, items, &choice); err != nil {
+			return nil, err
+		}
+		if choice == reviewDone {
+			break
+		}
+
+		action, err := promptForReviewAction(ctx, items, choice)
+		if err != nil {
+			return nil, err
+		}
+
+		switch action {
+		case reviewEdit:
+			if err := promptForEdit(ctx, items, choice); err != nil {
+				return nil, err
+			}
+		case reviewReveal:
+			if err := promptForReveal(ctx, items[choice].Question); err != nil {
+				return nil, err
+			}
+		case reviewMoveUp:
+			items[choice-1], items[choice] = items[choice], items[choice-1]
+			choice--
+		case reviewMoveDown:
+			items[choice+1], items[choice] = items[choice], items[choice+1]
+			choice++
+		case reviewDelete:
+			secmem.Wipe(items[choice].Answer)
+			items = slices.Delete(items, choice, choice+1)
+			choice = reviewDone
+		}
+	}
+
+	reviewed := amnesia.NewQuestions()
+	changed := amnesia.NewQuestions()
+
+	for i, item := range items {
+		reviewed.Set(i, item.Question)
+
+		if item.changed {
+			changed.Set(i, item.Question)
+		}
+	}
+
+	if testQuestions && len(changed) > 0 {
+		if err := promptForTestQuestions(ctx, changed); err != nil {
+			return nil, err
+		}
+	}
+
+	return reviewed, nil
+}
+
+func promptForReview(ctx context.Context, items []reviewItem, choice *int) error {
+	var options []huh.Option[int]
+
+	for i, item := range items {
+		options = append(options, huh.NewOption(fmt.Sprintf("%d. %s  ********", i+1, item.Question.Question), i))
+	}
+
+	options = append(options, huh.NewOption("Done", reviewDone))
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[int]().
+				Title("Review questions").
+				Description("Select a question to edit, delete, reorder or reveal its answer").
+				Options(options...).
+				Value(choice),
+		),
+	)
+
+	return form.RunWithContext(ctx)
+}
+
+func promptForReviewAction(ctx context.Context, items []reviewItem, idx int) (reviewAction, error) {
+	var action reviewAction
+
+	options := []huh.Option[reviewAction]{
+		huh.NewOption("Edit", reviewEdit),
+		huh.NewOption("Reveal answer", reviewReveal),
+	}
+
+	if idx > 0 {
+		options = append(options, huh.NewOption("Move up", reviewMoveUp))
+	}
+	if idx < len(items)-1 {
+		options = append(options, huh.NewOption("Move down", reviewMoveDown))
+	}
+
+	options = append(options,
+		huh.NewOption("Delete", reviewDelete),
+		huh.NewOption("Back", reviewBack),
+	)
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[reviewAction]().
+				Title(items[idx].Question.Question).
+				Options(options...).
+				Value(&action).
+				Validate(func(a reviewAction) error {
+					if a == reviewDelete && len(items) <= amnesia.MinQuestions {
+						return fmt.Errorf("at least two questions are required")
+					}
+					return nil
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return reviewBack, err
+	}
+
+	return action, nil
+}
+
+func promptForEdit(ctx context.Context, items []reviewItem, idx int) error {
+	question := items[idx].Question.Question
+	answer := string(items[idx].Answer)
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Edit question").
+				Value(&question).
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("string cannot be empty")
+					}
+					for i, item := range items {
+						if i != idx && item.Question.Question == s {
+							return fmt.Errorf("question already set")
+						}
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Edit answer").
+				EchoMode(huh.EchoModePassword).
+				Value(&answer).
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("answer cannot be empty")
+					}
+					return nil
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return err
+	}
+
+	if question != items[idx].Question.Question || subtle.ConstantTimeCompare([]byte(answer), items[idx].Answer) != 1 {
+		secmem.Wipe(items[idx].Answer)
+		items[idx].Question = amnesia.Question{
+			Question: question,
+			Answer:   []byte(answer),
+		}
+		items[idx].changed = true
+	}
+
+	return nil
+}
+
+func promptForReveal(ctx context.Context, question amnesia.Question) error {
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title(question.Question).
+				Description(noteEscaper.Replace(string(question.Answer))).
+				Next(true).
+				NextLabel("Hide"),
+		),
+	)
+
+	return form.RunWithContext(ctx)
+}
+
 func promptForTestQuestions(ctx context.Context, questions amnesia.Questions) error {
 	var fields []huh.Field
 
@@ -190,7 +804,7 @@ func promptForTestQuestions(ctx context.Context, questions amnesia.Questions) er
 			Description("Enter the answer to the test question").
 			EchoMode(huh.EchoModePassword).
 			Validate(func(s string) error {
-				if s != question.Answer {
+				if subtle.ConstantTimeCompare([]byte(s), question.Answer) != 1 {
 					return fmt.Errorf("incorrect answer")
 				}
 				return nil
@@ -235,14 +849,14 @@ func promptForThreshold(ctx context.Context, numQuestions int) (int, error) {
 	return threshold, nil
 }
 
-func promptForAnswer(ctx context.Context, question string) (string, error) {
-	var answer string
+func promptForAnswer(ctx context.Context, question, current string) (string, error) {
+	answer := current
 
 	form := huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
 				Title(question).
-				Description("If you don't know the answer, leave it blank").
+				Description("Leave blank to skip this question").
 				EchoMode(huh.EchoModePassword).
 				Value(&answer),
 		),
@@ -254,3 +868,29 @@ func promptForAnswer(ctx context.Context, question string) (string, error) {
 
 	return answer, nil
 }
+
+func promptForKeyfile(ctx context.Context, description string) ([]byte, error) {
+	var path string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Enter path to keyfile").
+				Description(description).
+				Value(&path).
+				Validate(func(s string) error {
+					f, err := os.Open(s)
+					if err != nil {
+						return err
+					}
+					return f.Close()
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
@@ -0,0 +1,97 @@
+package interactive
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+type progressMsg struct {
+	done  int
+	total int
+}
+
+type progressModel struct {
+	title   string
+	bar     progress.Model
+	done    int
+	total   int
+	percent float64
+	cancel  context.CancelFunc
+}
+
+func (m progressModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.cancel()
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.bar.Width = min(msg.Width, 80)
+	case progressMsg:
+		m.done, m.total = msg.done, msg.total
+		m.percent = float64(msg.done) / float64(msg.total)
+	}
+
+	return m, nil
+}
+
+func (m progressModel) View() string {
+	return fmt.Sprintf("%s (%d/%d)\n%s\n", m.title, m.done, m.total, m.bar.ViewAs(m.percent))
+}
+
+// withProgress runs fn while displaying a progress bar fed by the KDF progress
+// callback. Pressing Ctrl+C cancels the context passed to fn. The bar is drawn
+// on stderr like the prompts, so it never mixes with output on stdout, and
+// isn't drawn at all if stderr isn't a terminal.
+func withProgress[T any](ctx context.Context, title string, fn func(context.Context, amnesia.Option) (T, error)) (T, error) {
+	if !term.IsTerminal(os.Stderr.Fd()) {
+		return fn(ctx, amnesia.WithProgress(func(done, total int) {}))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	program := tea.NewProgram(progressModel{
+		title:  title,
+		bar:    progress.New(progress.WithDefaultGradient()),
+		cancel: cancel,
+	}, tea.WithOutput(os.Stderr))
+
+	var (
+		result T
+		err    error
+		doneCh = make(chan struct{})
+	)
+
+	go func() {
+		defer close(doneCh)
+		defer program.Quit()
+
+		result, err = fn(ctx, amnesia.WithProgress(func(done, total int) {
+			program.Send(progressMsg{done: done, total: total})
+		}))
+	}()
+
+	if _, runErr := program.Run(); runErr != nil {
+		cancel()
+		<-doneCh
+
+		var zero T
+		return zero, runErr
+	}
+
+	<-doneCh
+
+	return result, err
+}
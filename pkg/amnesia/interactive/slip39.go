@@ -0,0 +1,104 @@
+package interactive
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"github.com/cedws/amnesia/pkg/amnesia/slip39"
+	"github.com/charmbracelet/huh"
+)
+
+// MnemonicSpec describes how to split a secret into SLIP-39 mnemonics
+type MnemonicSpec struct {
+	// Secret splits the unsealed secret instead of the data encryption key
+	Secret            bool
+	GroupThreshold    int
+	Groups            []slip39.Group
+	IterationExponent int
+	// Passphrase prompts for a passphrase which is required to recover
+	Passphrase bool
+}
+
+// ExportMnemonics unlocks the sealed secret and splits its data encryption
+// key, or the secret itself, into SLIP-39 mnemonics
+func ExportMnemonics(ctx context.Context, sealed []byte, spec MnemonicSpec, opts ...Option) ([][]string, error) {
+	key, err := DecryptKey(ctx, sealed, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	secret := key
+	if spec.Secret {
+		if secret, err = amnesia.UnsealWithKey(sealed, key.Bytes()); err != nil {
+			return nil, err
+		}
+		defer secret.Destroy()
+	}
+
+	var passphrase []byte
+	if spec.Passphrase {
+		if passphrase, err = promptForNewPassphrase(ctx, "This passphrase will be required along with the mnemonics"); err != nil {
+			return nil, err
+		}
+		defer secmem.Wipe(passphrase)
+	}
+
+	return slip39.Split(rand.Reader, secret.Bytes(), passphrase, spec.GroupThreshold, spec.Groups, spec.IterationExponent)
+}
+
+// ImportMnemonics recovers the secret encoded by SLIP-39 mnemonics, prompting
+// for them if none are given
+func ImportMnemonics(ctx context.Context, mnemonics []string, passphrase bool) (*secmem.Buffer, error) {
+	if len(mnemonics) == 0 {
+		var err error
+		if mnemonics, err = promptForMnemonics(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var p []byte
+	if passphrase {
+		var err error
+		if p, err = promptForPassphrase(ctx); err != nil {
+			return nil, err
+		}
+		defer secmem.Wipe(p)
+	}
+
+	secret, err := slip39.Combine(mnemonics, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return secmem.Copy(secret), nil
+}
+
+func promptForMnemonics(ctx context.Context) ([]string, error) {
+	var text string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewText().
+				Title("Enter SLIP-39 mnemonics").
+				Description("One mnemonic per line").
+				Value(&text).
+				Validate(func(s string) error {
+					if strings.TrimSpace(s) == "" {
+						return fmt.Errorf("at least one mnemonic is required")
+					}
+					return nil
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return slip39.ParseMnemonics(text), nil
+}
@@ -0,0 +1,301 @@
+package interactive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"github.com/charmbracelet/huh"
+)
+
+// AddSlot unlocks the sealed secret and adds a slot to it. Anything the spec
+// leaves unset is prompted for.
+func AddSlot(ctx context.Context, sealed []byte, spec amnesia.SlotSpec, opts ...Option) ([]byte, error) {
+	options := newOptions(opts)
+
+	key, err := DecryptKey(ctx, sealed, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	if spec.Type == "" {
+		if spec.Type, err = promptForSlotType(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	switch spec.Type {
+	case amnesia.SlotQuestions:
+		questions, threshold, err := promptForQuestionSet(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { questions.Wipe() }()
+
+		spec.Questions, spec.Threshold = questions, threshold
+		if spec.Trustees == nil {
+			spec.Trustees = options.trustees
+		}
+	case amnesia.SlotPassphrase:
+		if spec.Passphrase == nil {
+			if spec.Passphrase, err = promptForNewPassphrase(ctx, "This passphrase will unseal the secret on its own"); err != nil {
+				return nil, err
+			}
+			defer secmem.Wipe(spec.Passphrase)
+		}
+	case amnesia.SlotAge:
+		if spec.Recipient == "" {
+			if spec.Recipient, err = promptForRecipient(ctx); err != nil {
+				return nil, err
+			}
+		}
+	case amnesia.SlotKeyfile:
+		if spec.Keyfile == nil {
+			if spec.Keyfile, err = promptForKeyfile(ctx, "This keyfile will unlock the secret on its own"); err != nil {
+				return nil, err
+			}
+			defer secmem.Wipe(spec.Keyfile)
+		}
+	}
+
+	return withProgress(ctx, "Deriving keys", func(ctx context.Context, progress amnesia.Option) ([]byte, error) {
+		return amnesia.AddSlot(ctx, sealed, key.Bytes(), spec, progress)
+	})
+}
+
+// RemoveSlot unlocks the sealed secret and removes a slot from it
+func RemoveSlot(ctx context.Context, sealed []byte, id int, opts ...Option) ([]byte, error) {
+	key, err := DecryptKey(ctx, sealed, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	return amnesia.RemoveSlot(sealed, key.Bytes(), id)
+}
+
+// DescribeSlot summarises a slot in a single line
+func DescribeSlot(slot *amnesia.Slot) string {
+	var desc string
+
+	switch slot.Type {
+	case amnesia.SlotQuestions:
+		desc = fmt.Sprintf("%d shares, %d required", len(slot.Shares), max(slot.Threshold, amnesia.MinQuestions))
+		if slot.RequiresKeyfile() {
+			desc += ", keyfile required"
+		}
+	case amnesia.SlotAge:
+		desc = slot.Recipient
+	}
+
+	s := fmt.Sprintf("%d: %s", slot.ID, slot.Type)
+	if slot.Label != "" {
+		s += fmt.Sprintf(" %q", slot.Label)
+	}
+	if desc != "" {
+		s += fmt.Sprintf(" (%s)", desc)
+	}
+
+	return s
+}
+
+func promptForSlot(ctx context.Context, slots []amnesia.Slot) (*amnesia.Slot, error) {
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("no slots")
+	}
+	if len(slots) == 1 {
+		return &slots[0], nil
+	}
+
+	var options []huh.Option[int]
+	for i := range slots {
+		options = append(options, huh.NewOption(DescribeSlot(&slots[i]), i))
+	}
+
+	var choice int
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[int]().
+				Title("Select a slot to unlock").
+				Description("Any one slot is enough to unseal the secret").
+				Options(options...).
+				Value(&choice),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return &slots[choice], nil
+}
+
+func promptForSlotType(ctx context.Context) (amnesia.SlotType, error) {
+	var slotType amnesia.SlotType
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[amnesia.SlotType]().
+				Title("Select slot type").
+				Options(
+					huh.NewOption("Questions", amnesia.SlotQuestions),
+					huh.NewOption("Passphrase", amnesia.SlotPassphrase),
+					huh.NewOption("age recipient", amnesia.SlotAge),
+					huh.NewOption("Keyfile", amnesia.SlotKeyfile),
+				).
+				Value(&slotType),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return "", err
+	}
+
+	return slotType, nil
+}
+
+// promptForQuestionSet runs the same question, review and threshold steps as
+// sealing
+func promptForQuestionSet(ctx context.Context, options *options) (amnesia.Questions, int, error) {
+	questions, err := promptForQuestions(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if options.testQuestions {
+		if err := promptForTestQuestions(ctx, questions); err != nil {
+			questions.Wipe()
+			return nil, 0, err
+		}
+	}
+
+	reviewed, err := reviewQuestions(ctx, questions, options.testQuestions)
+	if err != nil {
+		questions.Wipe()
+		return nil, 0, err
+	}
+
+	threshold, err := promptForThreshold(ctx, len(reviewed)+len(options.trustees))
+	if err != nil {
+		reviewed.Wipe()
+		return nil, 0, err
+	}
+
+	return reviewed, threshold, nil
+}
+
+func promptForPassphrase(ctx context.Context) ([]byte, error) {
+	var passphrase string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Enter passphrase").
+				EchoMode(huh.EchoModePassword).
+				Value(&passphrase),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return []byte(passphrase), nil
+}
+
+func promptForNewPassphrase(ctx context.Context, description string) ([]byte, error) {
+	var passphrase, confirm string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Enter passphrase").
+				Description(description).
+				EchoMode(huh.EchoModePassword).
+				Value(&passphrase).
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("passphrase cannot be empty")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Confirm passphrase").
+				EchoMode(huh.EchoModePassword).
+				Value(&confirm).
+				Validate(func(s string) error {
+					if s != passphrase {
+						return fmt.Errorf("passphrases don't match")
+					}
+					return nil
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return []byte(passphrase), nil
+}
+
+func promptForRecipient(ctx context.Context) (string, error) {
+	var recipient string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Enter age recipient").
+				Description("The matching identity will unseal the secret on its own").
+				Value(&recipient).
+				Validate(func(s string) error {
+					_, err := age.ParseRecipients(strings.NewReader(s))
+					return err
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return "", err
+	}
+
+	return recipient, nil
+}
+
+func promptForIdentities(ctx context.Context) ([]age.Identity, error) {
+	var path string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Enter path to age identity file").
+				Value(&path).
+				Validate(func(s string) error {
+					f, err := os.Open(s)
+					if err != nil {
+						return err
+					}
+					return f.Close()
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(data)
+
+	return age.ParseIdentities(bytes.NewReader(data))
+}
@@ -0,0 +1,30 @@
+package interactive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+)
+
+// ConfirmSignature asks whether a signature with the SSH key may be made
+func ConfirmSignature(ctx context.Context, comment, fingerprint string) (bool, error) {
+	var allow bool
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Allow use of SSH key?").
+				Description(fmt.Sprintf("%s\n%s", noteEscaper.Replace(comment), fingerprint)).
+				Affirmative("Allow").
+				Negative("Deny").
+				Value(&allow),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		return false, err
+	}
+
+	return allow, nil
+}
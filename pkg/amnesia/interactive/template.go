@@ -0,0 +1,65 @@
+package interactive
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/charmbracelet/huh"
+)
+
+// PromptForTemplate asks for questions without answers and the threshold, for
+// answers to be given later
+func PromptForTemplate(ctx context.Context) ([]string, int, error) {
+	var questions []string
+	cont := true
+
+	for cont {
+		var question string
+
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Enter a question").
+					Description("Answers are given each time a file is encrypted").
+					Value(&question).
+					Validate(func(s string) error {
+						if s == "" {
+							return fmt.Errorf("string cannot be empty")
+						}
+						if slices.Contains(questions, s) {
+							return fmt.Errorf("question already set")
+						}
+						return nil
+					}),
+				huh.NewConfirm().
+					Title("Enter another question?").
+					Value(&cont).
+					Validate(func(b bool) error {
+						// -1 because question hasn't been added yet
+						if !b && len(questions) < amnesia.MinQuestions-1 {
+							return fmt.Errorf("at least two questions are required")
+						}
+						return nil
+					}),
+			),
+		)
+		if err := form.RunWithContext(ctx); err != nil {
+			return nil, 0, err
+		}
+
+		questions = append(questions, question)
+
+		if len(questions) == amnesia.MaxQuestions {
+			break
+		}
+	}
+
+	threshold, err := promptForThreshold(ctx, len(questions))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return questions, threshold, nil
+}
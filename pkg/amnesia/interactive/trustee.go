@@ -0,0 +1,87 @@
+package interactive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"github.com/charmbracelet/huh"
+)
+
+// loadTrusteeShare reads a trustee's decrypted share file, or an identity
+// file that decrypts the share
+func loadTrusteeShare(path string, share *amnesia.Share) (*amnesia.TrusteeShare, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Wipe(data)
+
+	if trusteeShare, err := amnesia.ParseTrusteeShare(data); err == nil {
+		if trusteeShare.ID != share.ID {
+			trusteeShare.Wipe()
+			return nil, fmt.Errorf("share file is for share %d", trusteeShare.ID)
+		}
+
+		return trusteeShare, nil
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not a share or identity file")
+	}
+
+	trusteeShare, err := share.DecryptTrustee(identities...)
+	if err != nil {
+		return nil, err
+	}
+	if trusteeShare == nil {
+		return nil, fmt.Errorf("identity doesn't match this trustee")
+	}
+
+	return trusteeShare, nil
+}
+
+// promptForTrusteeShare asks for a trustee's share or identity file. A blank
+// path clears the share and returns nil.
+func promptForTrusteeShare(ctx context.Context, share *amnesia.Share) (*amnesia.TrusteeShare, error) {
+	var (
+		path         string
+		trusteeShare *amnesia.TrusteeShare
+	)
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title(fmt.Sprintf("Trustee %s", share.Recipient)).
+				Description("Enter path to the trustee's decrypted share or their identity file, leave blank to skip").
+				Value(&path).
+				Validate(func(s string) error {
+					if trusteeShare != nil {
+						trusteeShare.Wipe()
+						trusteeShare = nil
+					}
+					if s == "" {
+						return nil
+					}
+
+					var err error
+					trusteeShare, err = loadTrusteeShare(s, share)
+					return err
+				}),
+		),
+	)
+
+	if err := form.RunWithContext(ctx); err != nil {
+		if trusteeShare != nil {
+			trusteeShare.Wipe()
+		}
+		return nil, err
+	}
+
+	return trusteeShare, nil
+}
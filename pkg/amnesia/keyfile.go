@@ -0,0 +1,139 @@
+package amnesia
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+var (
+	ErrKeyfileRequired    = errors.New("a keyfile is required to unseal this secret")
+	ErrIncorrectKeyfile   = errors.New("incorrect keyfile")
+	ErrUnsupportedKeyfile = fmt.Errorf("format version %s doesn't support keyfiles", FormatV1)
+)
+
+// KeyfileParams are stored when a keyfile is required in addition to answers.
+// The DEK is derived from both the joined shares and the keyfile so neither is
+// sufficient alone.
+type KeyfileParams struct {
+	Salt string `json:"salt"`
+	// Check allows an incorrect keyfile to be detected before running the KDF
+	Check string `json:"check"`
+}
+
+// RequiresKeyfile reports whether a keyfile is needed in addition to answers
+func (s *ShareSet) RequiresKeyfile() bool {
+	return s.Keyfile != nil
+}
+
+// keyfileKey derives a key from the keyfile contents. Keyfiles are expected to
+// have high entropy so they're hashed rather than stretched.
+func keyfileKey(keyfile, salt []byte) (*secmem.Buffer, error) {
+	digest := sha256.Sum256(keyfile)
+	defer secmem.Wipe(digest[:])
+
+	key, err := hkdf.Key(sha256.New, digest[:], salt, "amnesia keyfile", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return secmem.Copy(key), nil
+}
+
+func keyfileCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("amnesia keyfile check"))
+
+	return mac.Sum(nil)[:16]
+}
+
+// mixKeyfile derives the DEK from the joined shares and the keyfile key
+func mixKeyfile(joined, key, salt []byte) (*secmem.Buffer, error) {
+	ikm := secmem.New(len(joined) + len(key))
+	defer ikm.Destroy()
+
+	copy(ikm.Bytes(), joined)
+	copy(ikm.Bytes()[len(joined):], key)
+
+	dek, err := hkdf.Key(sha256.New, ikm.Bytes(), salt, "amnesia keyfile dek", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return secmem.Copy(dek), nil
+}
+
+// newKeyfileKey derives a key from the keyfile with a fresh salt, and returns
+// the parameters to store
+func (s *Sealer) newKeyfileKey(keyfile []byte) (*KeyfileParams, *secmem.Buffer, []byte, error) {
+	salt, err := s.random(32)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := keyfileKey(keyfile, salt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	params := &KeyfileParams{
+		Salt:  encoding.EncodeToString(salt),
+		Check: encoding.EncodeToString(keyfileCheck(key.Bytes())),
+	}
+
+	return params, key, salt, nil
+}
+
+// sealKeyfile derives the DEK from the joined secret and the keyfile, and
+// returns the parameters to store
+func (s *Sealer) sealKeyfile(keyfile, joined []byte) (*KeyfileParams, *secmem.Buffer, error) {
+	params, key, salt, err := s.newKeyfileKey(keyfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer key.Destroy()
+
+	dekKey, err := mixKeyfile(joined, key.Bytes(), salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return params, dekKey, nil
+}
+
+// openKeyfile derives and checks the keyfile key. It returns nil if params is
+// nil, meaning no keyfile is required.
+func (s *Sealer) openKeyfile(params *KeyfileParams) (*secmem.Buffer, []byte, error) {
+	if params == nil {
+		return nil, nil, nil
+	}
+	if s.options.keyfile == nil {
+		return nil, nil, ErrKeyfileRequired
+	}
+
+	salt, err := encoding.DecodeString(params.Salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	check, err := encoding.DecodeString(params.Check)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := keyfileKey(s.options.keyfile, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !hmac.Equal(check, keyfileCheck(key.Bytes())) {
+		key.Destroy()
+		return nil, nil, ErrIncorrectKeyfile
+	}
+
+	return key, salt, nil
+}
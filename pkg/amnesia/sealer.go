@@ -0,0 +1,207 @@
+package amnesia
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"time"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// ProgressFunc is called after each KDF evaluation completes with the number
+// of evaluations done so far and the total
+type ProgressFunc func(done, total int)
+
+type options struct {
+	rand          io.Reader
+	now           func() time.Time
+	kdfParams     KDFParams
+	formatVersion string
+	progress      ProgressFunc
+	memoryBudget  uint64
+	keyfile       []byte
+	passphrase    []byte
+	identities    []age.Identity
+	trustees      []string
+	trusteeShares []*TrusteeShare
+	slot          int
+	bip39         bool
+}
+
+type Option func(*options)
+
+// WithRand sets the source of randomness for keys, salts, nonces and shares.
+// Defaults to crypto/rand.
+func WithRand(rand io.Reader) Option {
+	return func(o *options) {
+		o.rand = rand
+	}
+}
+
+// WithClock sets the clock used for the sealed timestamp. Defaults to
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithKDFParams sets the argon2id parameters used when sealing. Non-default
+// parameters require format version 2 or later.
+func WithKDFParams(params KDFParams) Option {
+	return func(o *options) {
+		o.kdfParams = params
+	}
+}
+
+// WithFormatVersion sets the format version written when sealing
+func WithFormatVersion(version string) Option {
+	return func(o *options) {
+		o.formatVersion = version
+	}
+}
+
+// WithProgress sets a callback for reporting KDF progress. Calls are
+// serialised but may come from different goroutines.
+func WithProgress(progress ProgressFunc) Option {
+	return func(o *options) {
+		o.progress = progress
+	}
+}
+
+// WithMemoryBudget sets the amount of memory in bytes that concurrent KDF
+// evaluations may use. At least one evaluation always runs.
+func WithMemoryBudget(budget uint64) Option {
+	return func(o *options) {
+		o.memoryBudget = budget
+	}
+}
+
+// WithKeyfile sets the contents of a keyfile. When sealing, the keyfile becomes
+// required in addition to answers. When unsealing, it's used if the sealed
+// secret requires one.
+func WithKeyfile(keyfile []byte) Option {
+	return func(o *options) {
+		o.keyfile = keyfile
+	}
+}
+
+// WithPassphrase sets the passphrase used to unlock passphrase slots
+func WithPassphrase(passphrase []byte) Option {
+	return func(o *options) {
+		o.passphrase = passphrase
+	}
+}
+
+// WithIdentities sets the age identities used to unlock age slots and decrypt
+// trustee shares
+func WithIdentities(identities ...age.Identity) Option {
+	return func(o *options) {
+		o.identities = identities
+	}
+}
+
+// WithTrustees sets age recipients that are each given a share when sealing,
+// in addition to the question shares
+func WithTrustees(recipients ...string) Option {
+	return func(o *options) {
+		o.trustees = recipients
+	}
+}
+
+// WithTrusteeShares sets shares already decrypted by their trustees or
+// ceremony participants, which count towards the threshold like answers
+func WithTrusteeShares(shares ...*TrusteeShare) Option {
+	return func(o *options) {
+		o.trusteeShares = shares
+	}
+}
+
+// WithSlot restricts unlocking to the slot with the given ID. By default every
+// slot the given credentials could unlock is tried.
+func WithSlot(id int) Option {
+	return func(o *options) {
+		o.slot = id
+	}
+}
+
+// WithBIP39 seals the secret as a BIP-39 mnemonic, storing its entropy rather
+// than the text. The mnemonic is validated and rebuilt when unsealing.
+func WithBIP39() Option {
+	return func(o *options) {
+		o.bip39 = true
+	}
+}
+
+type Sealer struct {
+	options options
+}
+
+func NewSealer(opts ...Option) *Sealer {
+	options := options{
+		rand:          rand.Reader,
+		now:           time.Now,
+		kdfParams:     DefaultKDFParams,
+		formatVersion: DefaultFormatVersion,
+		memoryBudget:  DefaultMemoryBudget,
+		slot:          anySlot,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Sealer{options: options}
+}
+
+func (s *Sealer) random(length int) ([]byte, error) {
+	buf := make([]byte, length)
+
+	if _, err := io.ReadFull(s.options.rand, buf); err != nil {
+		return nil, fmt.Errorf("error reading random bytes: %w", err)
+	}
+
+	return buf, nil
+}
+
+func Seal(
+	ctx context.Context,
+	secret []byte,
+	questions Questions,
+	threshold int,
+	opts ...Option,
+) ([]byte, error) {
+	return NewSealer(opts...).Seal(ctx, secret, questions, threshold)
+}
+
+func ResealWithKey(sealed, secret, key []byte, opts ...Option) ([]byte, error) {
+	return NewSealer(opts...).ResealWithKey(sealed, secret, key)
+}
+
+func Unseal(ctx context.Context, input []byte, answers Answers, opts ...Option) (*secmem.Buffer, error) {
+	return NewSealer(opts...).Unseal(ctx, input, answers)
+}
+
+func DecryptKey(ctx context.Context, sealedSecret *SealedSecret, answers Answers, opts ...Option) (*secmem.Buffer, error) {
+	return NewSealer(opts...).DecryptKey(ctx, sealedSecret, answers)
+}
+
+func AddSlot(ctx context.Context, sealed, key []byte, spec SlotSpec, opts ...Option) ([]byte, error) {
+	return NewSealer(opts...).AddSlot(ctx, sealed, key, spec)
+}
+
+func RemoveSlot(sealed, key []byte, id int, opts ...Option) ([]byte, error) {
+	return NewSealer(opts...).RemoveSlot(sealed, key, id)
+}
+
+func Contribute(ctx context.Context, sealedSecret *SealedSecret, answers Answers, recipient string, opts ...Option) ([]byte, error) {
+	return NewSealer(opts...).Contribute(ctx, sealedSecret, answers, recipient)
+}
+
+func wipeAll(bufs [][]byte) {
+	for _, buf := range bufs {
+		secmem.Wipe(buf)
+	}
+}
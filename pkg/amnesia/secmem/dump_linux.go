@@ -0,0 +1,18 @@
+package secmem
+
+import "golang.org/x/sys/unix"
+
+func adviseNoDump(mem []byte) {
+	_ = unix.Madvise(mem, unix.MADV_DONTDUMP)
+}
+
+// DisableCoreDumps stops the process from writing core dumps and, by clearing
+// the dumpable flag, from being attached to with ptrace by other unprivileged
+// processes
+func DisableCoreDumps() error {
+	if err := unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0); err != nil {
+		return err
+	}
+
+	return unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{})
+}
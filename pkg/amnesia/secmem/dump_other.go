@@ -0,0 +1,8 @@
+//go:build !unix
+
+package secmem
+
+// DisableCoreDumps is a no-op on this platform
+func DisableCoreDumps() error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build unix && !linux
+
+package secmem
+
+import "golang.org/x/sys/unix"
+
+func adviseNoDump([]byte) {}
+
+// DisableCoreDumps stops the process from writing core dumps
+func DisableCoreDumps() error {
+	return unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{})
+}
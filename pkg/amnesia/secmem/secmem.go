@@ -0,0 +1,69 @@
+// Package secmem provides buffers for sensitive data such as answers, keys and
+// plaintext. Buffers are allocated outside the Go heap, locked into memory
+// where supported so they aren't swapped, excluded from core dumps and zeroed
+// when destroyed.
+package secmem
+
+type Buffer struct {
+	data []byte
+	mem  []byte
+}
+
+// New allocates a zeroed buffer of the given size. If the buffer can't be
+// locked, for example due to RLIMIT_MEMLOCK, it may be swapped and a warning is
+// printed once. Where buffers can't be allocated outside the Go heap at all,
+// it falls back to the Go heap. Either way it's zeroed on destruction.
+func New(size int) *Buffer {
+	mem, err := alloc(size)
+	if err != nil {
+		return &Buffer{data: make([]byte, size)}
+	}
+
+	return &Buffer{
+		data: mem[:size:size],
+		mem:  mem,
+	}
+}
+
+// Copy allocates a buffer holding a copy of src, then wipes src
+func Copy(src []byte) *Buffer {
+	b := New(len(src))
+	copy(b.data, src)
+	Wipe(src)
+
+	return b
+}
+
+// Bytes returns the contents of the buffer. The slice must not be used after
+// the buffer is destroyed.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+
+func (b *Buffer) Len() int {
+	return len(b.Bytes())
+}
+
+// Destroy zeroes and releases the buffer. It is safe to call more than once
+// and on a nil buffer.
+func (b *Buffer) Destroy() {
+	if b == nil || b.data == nil {
+		return
+	}
+
+	Wipe(b.data)
+	if b.mem != nil {
+		free(b.mem)
+	}
+
+	b.data = nil
+	b.mem = nil
+}
+
+// Wipe zeroes buf
+func Wipe(buf []byte) {
+	clear(buf)
+}
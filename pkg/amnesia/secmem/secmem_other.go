@@ -0,0 +1,11 @@
+//go:build !unix
+
+package secmem
+
+import "errors"
+
+func alloc(int) ([]byte, error) {
+	return nil, errors.New("locked memory not supported")
+}
+
+func free([]byte) {}
@@ -0,0 +1,43 @@
+package secmem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffer(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		b := New(64)
+		assert.Equal(t, 64, b.Len())
+		assert.Equal(t, make([]byte, 64), b.Bytes())
+
+		b.Destroy()
+		assert.Nil(t, b.Bytes())
+
+		// Destroying twice is safe
+		b.Destroy()
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		b := New(0)
+		assert.Equal(t, 0, b.Len())
+		b.Destroy()
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		src := []byte("hunter2")
+
+		b := Copy(src)
+		defer b.Destroy()
+
+		assert.Equal(t, []byte("hunter2"), b.Bytes())
+		assert.Equal(t, make([]byte, 7), src)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var b *Buffer
+		assert.Nil(t, b.Bytes())
+		b.Destroy()
+	})
+}
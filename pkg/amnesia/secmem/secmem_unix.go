@@ -0,0 +1,40 @@
+//go:build unix
+
+package secmem
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var warnUnlocked sync.Once
+
+func alloc(size int) ([]byte, error) {
+	pageSize := os.Getpagesize()
+	// Round up to whole pages, and always map at least one
+	length := max(pageSize, (size+pageSize-1)/pageSize*pageSize)
+
+	mem, err := unix.Mmap(-1, 0, length, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+
+	// Locking may fail if RLIMIT_MEMLOCK is exhausted. The memory is still
+	// usable, so carry on after warning that it may be swapped.
+	if err := unix.Mlock(mem); err != nil {
+		warnUnlocked.Do(func() {
+			fmt.Fprintf(os.Stderr, "warning: can't lock memory, secrets may be swapped to disk: %v\n", err)
+		})
+	}
+	adviseNoDump(mem)
+
+	return mem, nil
+}
+
+func free(mem []byte) {
+	_ = unix.Munlock(mem)
+	_ = unix.Munmap(mem)
+}
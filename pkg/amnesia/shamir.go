@@ -0,0 +1,113 @@
+package amnesia
+
+import (
+	"fmt"
+	"io"
+)
+
+// splitSecret splits a secret into parts shares, threshold of which are
+// required to reconstruct it. The share format and field arithmetic match
+// github.com/hashicorp/vault/shamir so shares can be joined with its Combine,
+// but all randomness is drawn from r so output is reproducible.
+func splitSecret(r io.Reader, secret []byte, parts, threshold int) ([][]byte, error) {
+	if parts < threshold {
+		return nil, fmt.Errorf("parts cannot be less than threshold")
+	}
+	if parts > 255 {
+		return nil, fmt.Errorf("parts cannot exceed 255")
+	}
+	if threshold < 2 {
+		return nil, fmt.Errorf("threshold must be at least 2")
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("cannot split an empty secret")
+	}
+
+	xCoordinates, err := randomPerm(r, 255)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each share is {y1, y2, .., yN, x}
+	out := make([][]byte, parts)
+	for idx := range out {
+		out[idx] = make([]byte, len(secret)+1)
+		out[idx][len(secret)] = uint8(xCoordinates[idx]) + 1
+	}
+
+	coefficients := make([]byte, threshold)
+
+	for idx, val := range secret {
+		// Random polynomial of degree threshold-1 with the secret byte as intercept
+		coefficients[0] = val
+		if _, err := io.ReadFull(r, coefficients[1:]); err != nil {
+			return nil, err
+		}
+
+		for i := range parts {
+			out[i][idx] = evaluatePolynomial(coefficients, out[i][len(secret)])
+		}
+	}
+
+	return out, nil
+}
+
+// randomPerm returns a uniformly random permutation of [0, n) using a
+// Fisher-Yates shuffle
+func randomPerm(r io.Reader, n int) ([]int, error) {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+
+	for i := n - 1; i > 0; i-- {
+		j, err := randInt(r, i+1)
+		if err != nil {
+			return nil, err
+		}
+
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+
+	return perm, nil
+}
+
+// randInt returns a uniform random integer in [0, n) for n <= 256
+func randInt(r io.Reader, n int) (int, error) {
+	var b [1]byte
+
+	// Reject values past the largest multiple of n to avoid modulo bias
+	limit := 256 - 256%n
+
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		if int(b[0]) < limit {
+			return int(b[0]) % n, nil
+		}
+	}
+}
+
+// evaluatePolynomial evaluates the polynomial at x using Horner's method
+func evaluatePolynomial(coefficients []byte, x uint8) uint8 {
+	degree := len(coefficients) - 1
+	out := coefficients[degree]
+
+	for i := degree - 1; i >= 0; i-- {
+		out = gfMult(out, x) ^ coefficients[i]
+	}
+
+	return out
+}
+
+// gfMult multiplies two numbers in GF(2^8) without branching on secret data
+func gfMult(a, b uint8) uint8 {
+	var r uint8
+
+	for i := 7; i >= 0; i-- {
+		r = (-(b >> i & 1) & a) ^ (-(r >> 7) & 0x1b) ^ (r + r)
+	}
+
+	return r
+}
@@ -0,0 +1,252 @@
+package amnesia
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ShareFileV1 is the first version of the share file format
+const ShareFileV1 = "1"
+
+var ErrChecksumMismatch = errors.New("share file checksum mismatch")
+
+// ShareFile is a single share exported for storing apart from the sealed file.
+// The share stays encrypted under its question, or to its trustee.
+type ShareFile struct {
+	Version string `json:"version"`
+	// Fingerprint identifies the share set the share belongs to
+	Fingerprint string     `json:"fingerprint"`
+	Threshold   int        `json:"threshold,omitempty"`
+	KDF         *KDFParams `json:"kdf,omitempty"`
+	Share       Share      `json:"share"`
+	// Checksum covers the rest of the file to catch corruption, such as
+	// mistakes when copying it from paper
+	Checksum string `json:"checksum"`
+}
+
+// Fingerprint identifies the share set. It covers the IDs and salts or
+// recipients of every share, which don't change when shares are exported or
+// the secret is resealed.
+func (s *ShareSet) Fingerprint() string {
+	h := sha256.New()
+	h.Write([]byte("amnesia share set"))
+
+	for _, share := range s.Shares {
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(share.ID)))
+
+		field := share.Salt
+		if share.IsTrustee() {
+			field = share.Recipient
+		}
+
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		h.Write([]byte(field))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (f *ShareFile) checksum() (string, error) {
+	unsummed := *f
+	unsummed.Checksum = ""
+
+	data, err := json.Marshal(unsummed)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+func ParseShareFile(data []byte) (*ShareFile, error) {
+	var f ShareFile
+
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("error parsing share file: %w", err)
+	}
+	if f.Version != ShareFileV1 {
+		return nil, fmt.Errorf("unknown share file version: %s", f.Version)
+	}
+	if f.KDF != nil {
+		if err := f.KDF.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid kdf parameters: %w", err)
+		}
+	}
+
+	checksum, err := f.checksum()
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(checksum), []byte(f.Checksum)) != 1 {
+		return nil, ErrChecksumMismatch
+	}
+
+	return &f, nil
+}
+
+// Fingerprint identifies the sealed secret's DEK. Like the share set
+// fingerprint it doesn't change when the secret is resealed or shares are
+// exported, but adding or removing a slot changes it.
+func (s *SealedSecret) Fingerprint() string {
+	if s.Version != FormatV3 {
+		return s.ShareSet.Fingerprint()
+	}
+
+	h := sha256.New()
+	h.Write([]byte("amnesia slots"))
+
+	for _, slot := range s.Slots {
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(slot.ID)))
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(slot.WrappedKey))))
+		h.Write(slot.WrappedKey)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// shareSets returns every share set in the sealed secret
+func (s *SealedSecret) shareSets() []*ShareSet {
+	if s.Version != FormatV3 {
+		return []*ShareSet{&s.ShareSet}
+	}
+
+	var sets []*ShareSet
+	for i := range s.Slots {
+		if s.Slots[i].Type == SlotQuestions {
+			sets = append(sets, &s.Slots[i].ShareSet)
+		}
+	}
+
+	return sets
+}
+
+// shareSetKDF returns the KDF parameters of one of the sealed secret's share
+// sets
+func (s *SealedSecret) shareSetKDF(set *ShareSet) (KDFParams, error) {
+	if s.Version == FormatV3 {
+		return validKDFParams(set.KDF)
+	}
+
+	return s.kdfParams()
+}
+
+// findShare returns the share set and index of the share with the given ID
+func (s *SealedSecret) findShare(id int) (*ShareSet, int, error) {
+	for _, set := range s.shareSets() {
+		for i, share := range set.Shares {
+			if share.ID == id {
+				return set, i, nil
+			}
+		}
+	}
+
+	return nil, 0, fmt.Errorf("no share with id %d", id)
+}
+
+// ExportShare exports the share with the given ID to a share file. If remove
+// is set, the share is also removed from the sealed secret, leaving its
+// question and salt so it can be imported again, and the updated sealed secret
+// is returned.
+func ExportShare(sealed []byte, id int, remove bool) ([]byte, []byte, error) {
+	sealedSecret, err := Decode(sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	set, idx, err := sealedSecret.findShare(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if set.Shares[idx].Exported {
+		return nil, nil, fmt.Errorf("share %d has already been exported", id)
+	}
+
+	var kdf *KDFParams
+	if !set.Shares[idx].IsTrustee() {
+		params, err := sealedSecret.shareSetKDF(set)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		kdf = &params
+	}
+
+	shareFile := ShareFile{
+		Version:     ShareFileV1,
+		Fingerprint: set.Fingerprint(),
+		Threshold:   set.Threshold,
+		KDF:         kdf,
+		Share:       set.Shares[idx],
+	}
+
+	shareFile.Checksum, err = shareFile.checksum()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exported, err := json.MarshalIndent(shareFile, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !remove {
+		return exported, nil, nil
+	}
+
+	set.Shares[idx].Exported = true
+	set.Shares[idx].Share = ""
+
+	updated, err := encodeIndented(sealedSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return exported, updated, nil
+}
+
+// ImportShare merges a share file back into the sealed secret it was exported
+// from
+func ImportShare(sealed, data []byte) ([]byte, error) {
+	shareFile, err := ParseShareFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sealedSecret, err := Decode(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	set, idx, err := sealedSecret.findShare(shareFile.Share.ID)
+	if err != nil {
+		return nil, err
+	}
+	if set.Fingerprint() != shareFile.Fingerprint {
+		return nil, fmt.Errorf("share file doesn't belong to this sealed file")
+	}
+
+	share := &set.Shares[idx]
+	if !share.Exported {
+		if share.Share != shareFile.Share.Share {
+			return nil, fmt.Errorf("share %d differs from the share file", share.ID)
+		}
+
+		// Already present, nothing to do
+		return sealed, nil
+	}
+
+	if share.Salt != shareFile.Share.Salt || share.Recipient != shareFile.Share.Recipient {
+		return nil, fmt.Errorf("share %d doesn't match the share file", share.ID)
+	}
+
+	share.Exported = false
+	share.Share = shareFile.Share.Share
+
+	return encodeIndented(sealedSecret)
+}
@@ -0,0 +1,199 @@
+package slip39
+
+import (
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	radixBits     = 10
+	radix         = 1 << radixBits
+	checksumWords = 3
+	// id, extendable flag and iteration exponent, then group and member
+	// parameters, two words each
+	metadataWords = 4 + checksumWords
+	minWords      = metadataWords + (minSecretBytes*8+radixBits-1)/radixBits
+)
+
+var (
+	customization           = []byte("shamir")
+	customizationExtendable = []byte("shamir_extendable")
+)
+
+//go:embed wordlist.txt
+var wordlistData string
+
+var (
+	wordlist = strings.Fields(wordlistData)
+	wordIdx  = func() map[string]int {
+		m := make(map[string]int, len(wordlist))
+		for i, word := range wordlist {
+			m[word] = i
+		}
+		return m
+	}()
+)
+
+// share is a single decoded mnemonic
+type share struct {
+	id                int
+	extendable        bool
+	iterationExponent int
+	groupIndex        int
+	groupThreshold    int
+	groupCount        int
+	memberIndex       int
+	memberThreshold   int
+	value             []byte
+}
+
+func (s *share) customization() []byte {
+	if s.extendable {
+		return customizationExtendable
+	}
+	return customization
+}
+
+// commonParams are the parameters every share of a secret has in common
+func (s *share) commonParams() [5]int {
+	ext := 0
+	if s.extendable {
+		ext = 1
+	}
+	return [5]int{s.id, ext, s.iterationExponent, s.groupThreshold, s.groupCount}
+}
+
+func (s *share) mnemonic() string {
+	ext := 0
+	if s.extendable {
+		ext = 1
+	}
+
+	idExp := s.id<<5 | ext<<4 | s.iterationExponent
+	params := s.groupIndex<<16 | (s.groupThreshold-1)<<12 | (s.groupCount-1)<<8 | s.memberIndex<<4 | (s.memberThreshold - 1)
+
+	indices := []int{
+		idExp >> radixBits, idExp & (radix - 1),
+		params >> radixBits, params & (radix - 1),
+	}
+
+	valueWords := (len(s.value)*8 + radixBits - 1) / radixBits
+	value := new(big.Int).SetBytes(s.value)
+	valueIndices := make([]int, valueWords)
+	for i := valueWords - 1; i >= 0; i-- {
+		valueIndices[i] = int(new(big.Int).And(value, big.NewInt(radix-1)).Int64())
+		value.Rsh(value, radixBits)
+	}
+	indices = append(indices, valueIndices...)
+
+	checksum := rs1024Checksum(s.customization(), indices)
+	indices = append(indices, checksum...)
+
+	words := make([]string, len(indices))
+	for i, idx := range indices {
+		words[i] = wordlist[idx]
+	}
+
+	return strings.Join(words, " ")
+}
+
+func parseMnemonic(mnemonic string) (*share, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < minWords {
+		return nil, fmt.Errorf("mnemonic must be at least %d words", minWords)
+	}
+
+	indices := make([]int, len(words))
+	for i, word := range words {
+		idx, ok := wordIdx[word]
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic word %q", word)
+		}
+		indices[i] = idx
+	}
+
+	padding := (radixBits * (len(words) - metadataWords)) % 16
+	if padding > 8 {
+		return nil, fmt.Errorf("invalid mnemonic length")
+	}
+
+	idExp := indices[0]<<radixBits | indices[1]
+	params := indices[2]<<radixBits | indices[3]
+
+	s := &share{
+		id:                idExp >> 5,
+		extendable:        idExp>>4&1 == 1,
+		iterationExponent: idExp & 0xf,
+		groupIndex:        params >> 16,
+		groupThreshold:    params>>12&0xf + 1,
+		groupCount:        params>>8&0xf + 1,
+		memberIndex:       params >> 4 & 0xf,
+		memberThreshold:   params&0xf + 1,
+	}
+
+	if !rs1024Verify(s.customization(), indices) {
+		return nil, fmt.Errorf("invalid mnemonic checksum")
+	}
+	if s.groupThreshold > s.groupCount {
+		return nil, fmt.Errorf("group threshold exceeds group count")
+	}
+
+	value := new(big.Int)
+	for _, idx := range indices[4 : len(indices)-checksumWords] {
+		value.Lsh(value, radixBits)
+		value.Or(value, big.NewInt(int64(idx)))
+	}
+
+	valueBytes := (radixBits*(len(words)-metadataWords) - padding) / 8
+	if value.BitLen() > valueBytes*8 {
+		return nil, fmt.Errorf("invalid mnemonic padding")
+	}
+	s.value = value.FillBytes(make([]byte, valueBytes))
+
+	return s, nil
+}
+
+var rs1024Gen = [...]int{
+	0xe0e040, 0x1c1c080, 0x3838100, 0x7070200, 0xe0e0009,
+	0x1c0c2412, 0x38086c24, 0x3090fc48, 0x21b1f890, 0x3f3f120,
+}
+
+func rs1024Polymod(customization []byte, values []int) int {
+	chk := 1
+
+	step := func(v int) {
+		b := chk >> 20
+		chk = (chk&0xfffff)<<10 ^ v
+		for i, gen := range rs1024Gen {
+			if b>>i&1 == 1 {
+				chk ^= gen
+			}
+		}
+	}
+
+	for _, c := range customization {
+		step(int(c))
+	}
+	for _, v := range values {
+		step(v)
+	}
+
+	return chk
+}
+
+func rs1024Checksum(customization []byte, values []int) []int {
+	polymod := rs1024Polymod(customization, append(values[:len(values):len(values)], 0, 0, 0)) ^ 1
+
+	checksum := make([]int, checksumWords)
+	for i := range checksum {
+		checksum[i] = polymod >> (radixBits * (checksumWords - 1 - i)) & (radix - 1)
+	}
+
+	return checksum
+}
+
+func rs1024Verify(customization []byte, values []int) bool {
+	return rs1024Polymod(customization, values) == 1
+}
@@ -0,0 +1,148 @@
+package slip39
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+const (
+	digestBytes = 4
+	digestIndex = 254
+	secretIndex = 255
+)
+
+// exp and log tables for GF(256) with the Rijndael polynomial, as used by
+// SLIP-39. This differs from the share layout of the questions so the two
+// can't be mixed.
+var gfExp, gfLog = func() (exp [255]byte, log [256]int) {
+	poly := 1
+	for i := range exp {
+		exp[i] = byte(poly)
+		log[poly] = i
+		poly = poly<<1 ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11b
+		}
+	}
+	return
+}()
+
+type rawShare struct {
+	x    int
+	data []byte
+}
+
+// interpolate evaluates the polynomial through the shares at x
+func interpolate(shares []rawShare, x int) ([]byte, error) {
+	for _, share := range shares {
+		if share.x == x {
+			return share.data, nil
+		}
+	}
+
+	length := len(shares[0].data)
+	for _, share := range shares {
+		if len(share.data) != length {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+	}
+
+	logProd := 0
+	for _, share := range shares {
+		logProd += gfLog[share.x^x]
+	}
+
+	result := make([]byte, length)
+
+	for _, share := range shares {
+		logBasis := logProd - gfLog[share.x^x]
+		for _, other := range shares {
+			if other.x != share.x {
+				logBasis -= gfLog[share.x^other.x]
+			}
+		}
+		logBasis = (logBasis%255 + 255) % 255
+
+		for i, v := range share.data {
+			if v != 0 {
+				result[i] ^= gfExp[(gfLog[v]+logBasis)%255]
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func createDigest(random, secret []byte) []byte {
+	mac := hmac.New(sha256.New, random)
+	mac.Write(secret)
+	return mac.Sum(nil)[:digestBytes]
+}
+
+func splitSecret(rand io.Reader, threshold, count int, secret []byte) ([]rawShare, error) {
+	if threshold < 1 || threshold > count || count > maxShares {
+		return nil, fmt.Errorf("invalid threshold %d of %d", threshold, count)
+	}
+
+	shares := make([]rawShare, 0, count)
+
+	if threshold == 1 {
+		for i := range count {
+			shares = append(shares, rawShare{x: i, data: append([]byte(nil), secret...)})
+		}
+		return shares, nil
+	}
+
+	for i := range threshold - 2 {
+		data := make([]byte, len(secret))
+		if _, err := io.ReadFull(rand, data); err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{x: i, data: data})
+	}
+
+	random := make([]byte, len(secret)-digestBytes)
+	if _, err := io.ReadFull(rand, random); err != nil {
+		return nil, err
+	}
+
+	base := append(shares[:len(shares):len(shares)],
+		rawShare{x: digestIndex, data: append(createDigest(random, secret), random...)},
+		rawShare{x: secretIndex, data: secret},
+	)
+
+	for i := threshold - 2; i < count; i++ {
+		data, err := interpolate(base, i)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, rawShare{x: i, data: data})
+	}
+
+	return shares, nil
+}
+
+func recoverSecret(threshold int, shares []rawShare) ([]byte, error) {
+	if threshold == 1 {
+		return shares[0].data, nil
+	}
+
+	secret, err := interpolate(shares, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	digestShare, err := interpolate(shares, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, random := digestShare[:digestBytes], digestShare[digestBytes:]
+	if !hmac.Equal(digest, createDigest(random, secret)) {
+		return nil, fmt.Errorf("invalid digest of the shared secret")
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,224 @@
+// Package slip39 implements SLIP-39 Shamir mnemonics, so secrets can be
+// written down as words and recovered with other SLIP-39 tools.
+package slip39
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	minSecretBytes = 16
+	maxShares      = 16
+	maxExponent    = 15
+	idBits         = 15
+	rounds         = 4
+	baseIterations = 10000
+)
+
+// Group is a group of member shares, Threshold of which are needed to recover
+// the group's share
+type Group struct {
+	Threshold int
+	Count     int
+}
+
+// Split splits the secret into groups of mnemonics. groupThreshold groups must
+// each be complete to recover the secret. The passphrase may be empty.
+func Split(rand io.Reader, secret, passphrase []byte, groupThreshold int, groups []Group, iterationExponent int) ([][]string, error) {
+	if len(secret) < minSecretBytes || len(secret)%2 != 0 {
+		return nil, fmt.Errorf("secret must be an even number of bytes, at least %d", minSecretBytes)
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if iterationExponent < 0 || iterationExponent > maxExponent {
+		return nil, fmt.Errorf("iteration exponent must be between 0 and %d", maxExponent)
+	}
+	if groupThreshold < 1 || groupThreshold > len(groups) {
+		return nil, fmt.Errorf("group threshold must be between 1 and the number of groups")
+	}
+	for _, group := range groups {
+		if group.Threshold == 1 && group.Count > 1 {
+			return nil, fmt.Errorf("groups with a threshold of 1 must have 1 share")
+		}
+	}
+
+	var idBytes [2]byte
+	if _, err := io.ReadFull(rand, idBytes[:]); err != nil {
+		return nil, err
+	}
+	id := int(binary.BigEndian.Uint16(idBytes[:])) & (1<<idBits - 1)
+
+	encrypted, err := encrypt(secret, passphrase, iterationExponent, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	groupShares, err := splitSecret(rand, groupThreshold, len(groups), encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	mnemonics := make([][]string, len(groups))
+
+	for i, group := range groups {
+		memberShares, err := splitSecret(rand, group.Threshold, group.Count, groupShares[i].data)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", i+1, err)
+		}
+
+		for _, member := range memberShares {
+			s := share{
+				id:                id,
+				extendable:        true,
+				iterationExponent: iterationExponent,
+				groupIndex:        i,
+				groupThreshold:    groupThreshold,
+				groupCount:        len(groups),
+				memberIndex:       member.x,
+				memberThreshold:   group.Threshold,
+				value:             member.data,
+			}
+			mnemonics[i] = append(mnemonics[i], s.mnemonic())
+		}
+	}
+
+	return mnemonics, nil
+}
+
+// Combine recovers the secret from enough mnemonics to meet the group
+// threshold. A wrong passphrase can't be detected and gives a different
+// secret.
+func Combine(mnemonics []string, passphrase []byte) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, fmt.Errorf("no mnemonics given")
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	var first *share
+
+	groups := make(map[int][]*share)
+
+	for i, mnemonic := range mnemonics {
+		s, err := parseMnemonic(mnemonic)
+		if err != nil {
+			return nil, fmt.Errorf("mnemonic %d: %w", i+1, err)
+		}
+
+		if first == nil {
+			first = s
+		} else if s.commonParams() != first.commonParams() {
+			return nil, fmt.Errorf("mnemonic %d belongs to a different secret", i+1)
+		}
+
+		members := groups[s.groupIndex]
+		if len(members) > 0 && members[0].memberThreshold != s.memberThreshold {
+			return nil, fmt.Errorf("mnemonic %d has a different member threshold to its group", i+1)
+		}
+		if !slices.ContainsFunc(members, func(m *share) bool { return m.memberIndex == s.memberIndex }) {
+			groups[s.groupIndex] = append(members, s)
+		}
+	}
+
+	var groupShares []rawShare
+
+	for _, index := range slices.Sorted(maps.Keys(groups)) {
+		members := groups[index]
+		threshold := members[0].memberThreshold
+		if len(members) < threshold {
+			continue
+		}
+
+		var raw []rawShare
+		for _, member := range members[:threshold] {
+			raw = append(raw, rawShare{x: member.memberIndex, data: member.value})
+		}
+
+		groupSecret, err := recoverSecret(threshold, raw)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", index+1, err)
+		}
+
+		groupShares = append(groupShares, rawShare{x: index, data: groupSecret})
+	}
+
+	if len(groupShares) < first.groupThreshold {
+		return nil, fmt.Errorf("%d of %d required groups complete", len(groupShares), first.groupThreshold)
+	}
+
+	encrypted, err := recoverSecret(first.groupThreshold, groupShares[:first.groupThreshold])
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(encrypted, passphrase, first.iterationExponent, first.id, first.extendable)
+}
+
+// ParseMnemonics splits text into mnemonics, one per line. Blank lines and
+// lines starting with # are skipped.
+func ParseMnemonics(text string) []string {
+	var mnemonics []string
+
+	for line := range strings.Lines(text) {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			mnemonics = append(mnemonics, line)
+		}
+	}
+
+	return mnemonics
+}
+
+// checkPassphrase checks the passphrase is printable ASCII, as SLIP-39
+// requires
+func checkPassphrase(passphrase []byte) error {
+	for _, c := range passphrase {
+		if c < 32 || c > 126 {
+			return fmt.Errorf("passphrase must be printable ASCII")
+		}
+	}
+	return nil
+}
+
+func encrypt(secret, passphrase []byte, exponent, id int, extendable bool) ([]byte, error) {
+	return feistel(secret, passphrase, exponent, id, extendable, []int{0, 1, 2, 3})
+}
+
+func decrypt(encrypted, passphrase []byte, exponent, id int, extendable bool) ([]byte, error) {
+	return feistel(encrypted, passphrase, exponent, id, extendable, []int{3, 2, 1, 0})
+}
+
+// feistel runs the SLIP-39 Feistel network over data with PBKDF2 as the round
+// function, with the rounds in the given order
+func feistel(data, passphrase []byte, exponent, id int, extendable bool, order []int) ([]byte, error) {
+	var salt []byte
+	if !extendable {
+		salt = binary.BigEndian.AppendUint16(slices.Clone(customization), uint16(id))
+	}
+
+	half := len(data) / 2
+	l, r := slices.Clone(data[:half]), slices.Clone(data[half:])
+
+	for _, i := range order {
+		password := append([]byte{byte(i)}, passphrase...)
+		f, err := pbkdf2.Key(sha256.New, string(password), append(slices.Clone(salt), r...), (baseIterations<<exponent)/rounds, len(r))
+		if err != nil {
+			return nil, err
+		}
+
+		for j := range l {
+			l[j] ^= f[j]
+		}
+		l, r = r, l
+	}
+
+	return append(r, l...), nil
+}
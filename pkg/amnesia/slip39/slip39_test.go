@@ -0,0 +1,153 @@
+package slip39
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordlist(t *testing.T) {
+	assert.Len(t, wordlist, radix)
+
+	prefixes := make(map[string]bool)
+	for i, word := range wordlist {
+		if i > 0 {
+			assert.Less(t, wordlist[i-1], word)
+		}
+		prefixes[word[:4]] = true
+	}
+	assert.Len(t, prefixes, radix)
+}
+
+func TestVectors(t *testing.T) {
+	// From the SLIP-39 test vectors
+	tests := []struct {
+		name      string
+		mnemonics []string
+		secret    string
+	}{
+		{
+			name: "single share",
+			mnemonics: []string{
+				"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard",
+			},
+			secret: "bb54aac4b89dc868ba37d9cc21b2cece",
+		},
+		{
+			name: "2 of 3 shares",
+			mnemonics: []string{
+				"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+				"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking",
+			},
+			secret: "b43ceb7e57a0ea8766221624d01b0864",
+		},
+		{
+			name: "2 of 4 groups",
+			mnemonics: []string{
+				"eraser senior beard romp adorn nuclear spill corner cradle style ancient family general leader ambition exchange unusual garlic promise voice",
+				"eraser senior ceramic snake clay various huge numb argue hesitate auction category timber browser greatest hanger petition script leaf pickup",
+				"eraser senior ceramic shaft dynamic become junior wrist silver peasant force math alto coal amazing segment yelp velvet image paces",
+				"eraser senior ceramic round column hawk trust auction smug shame alive greatest sheriff living perfect corner chest sled fumes adequate",
+			},
+			secret: "7c3397a292a5941682d7a4ae2d898d11",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secret, err := Combine(test.mnemonics, []byte("TREZOR"))
+			assert.NoError(t, err)
+			assert.Equal(t, test.secret, hex.EncodeToString(secret))
+		})
+	}
+
+	t.Run("too few shares", func(t *testing.T) {
+		_, err := Combine(tests[1].mnemonics[:1], []byte("TREZOR"))
+		assert.Error(t, err)
+
+		// Three shares of the second group, but only one group
+		_, err = Combine(tests[2].mnemonics[1:], []byte("TREZOR"))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid checksum", func(t *testing.T) {
+		_, err := Combine([]string{
+			"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision kidney",
+		}, []byte("TREZOR"))
+		assert.Error(t, err)
+	})
+}
+
+func TestSplit(t *testing.T) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	assert.NoError(t, err)
+
+	passphrase := []byte("hunter2")
+
+	groups, err := Split(rand.Reader, secret, passphrase, 2, []Group{{2, 3}, {1, 1}, {3, 5}}, 0)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 3)
+	assert.Len(t, groups[0], 3)
+	assert.Len(t, groups[1], 1)
+	assert.Len(t, groups[2], 5)
+
+	t.Run("Combine", func(t *testing.T) {
+		recovered, err := Combine([]string{groups[0][2], groups[2][4], groups[0][0], groups[2][1], groups[2][3]}, passphrase)
+		assert.NoError(t, err)
+		assert.Equal(t, secret, recovered)
+
+		recovered, err = Combine([]string{groups[1][0], groups[0][1], groups[0][2]}, passphrase)
+		assert.NoError(t, err)
+		assert.Equal(t, secret, recovered)
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		// Any two complete groups recover the secret
+		complete := [][]string{groups[0][:2], groups[1], groups[2][:3]}
+
+		for i := range complete {
+			for j := i + 1; j < len(complete); j++ {
+				recovered, err := Combine(append(slices.Clone(complete[j]), complete[i]...), passphrase)
+				assert.NoError(t, err)
+				assert.Equal(t, secret, recovered)
+			}
+		}
+	})
+
+	t.Run("TooFew", func(t *testing.T) {
+		_, err := Combine([]string{groups[1][0], groups[0][1], groups[2][0], groups[2][1]}, passphrase)
+		assert.Error(t, err)
+	})
+
+	t.Run("WrongPassphrase", func(t *testing.T) {
+		recovered, err := Combine([]string{groups[1][0], groups[0][1], groups[0][2]}, []byte("hunter3"))
+		assert.NoError(t, err)
+		assert.NotEqual(t, secret, recovered)
+	})
+
+	t.Run("Mixed", func(t *testing.T) {
+		other, err := Split(rand.Reader, secret, nil, 1, []Group{{1, 1}}, 0)
+		assert.NoError(t, err)
+
+		_, err = Combine([]string{groups[1][0], other[0][0]}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := Split(rand.Reader, secret[:15], nil, 1, []Group{{1, 1}}, 0)
+		assert.Error(t, err)
+
+		_, err = Split(rand.Reader, secret, nil, 1, []Group{{1, 2}}, 0)
+		assert.Error(t, err)
+
+		_, err = Split(rand.Reader, secret, nil, 2, []Group{{1, 1}}, 0)
+		assert.Error(t, err)
+
+		_, err = Split(rand.Reader, secret, []byte("ünicode"), 1, []Group{{1, 1}}, 0)
+		assert.Error(t, err)
+	})
+}
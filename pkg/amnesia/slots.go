@@ -0,0 +1,451 @@
+package amnesia
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+type SlotType string
+
+const (
+	// SlotQuestions is unlocked by answering threshold of its questions
+	SlotQuestions SlotType = "questions"
+	// SlotPassphrase is unlocked by a passphrase
+	SlotPassphrase SlotType = "passphrase"
+	// SlotAge is unlocked by the age identity for its recipient
+	SlotAge SlotType = "age"
+	// SlotKeyfile is unlocked by the contents of a keyfile
+	SlotKeyfile SlotType = "keyfile"
+)
+
+// anySlot lets every slot be tried when unlocking
+const anySlot = -1
+
+var (
+	ErrNoSlot   = errors.New("no slot could be unlocked with the given credentials")
+	ErrLastSlot = errors.New("cannot remove the last slot")
+)
+
+// Slot independently wraps the DEK so any one slot is enough to unseal
+type Slot struct {
+	ID    int      `json:"id"`
+	Type  SlotType `json:"type"`
+	Label string   `json:"label,omitempty"`
+	// ShareSet holds the shares of a questions slot, the KDF parameters of a
+	// passphrase slot and the keyfile parameters of a keyfile slot
+	ShareSet
+	// Salt is the KDF salt of a passphrase slot
+	Salt string `json:"salt,omitempty"`
+	// Recipient is the age recipient of an age slot
+	Recipient  string `json:"recipient,omitempty"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// cost orders slots by how expensive they are to try
+func (s *Slot) cost() int {
+	switch s.Type {
+	case SlotKeyfile:
+		return 0
+	case SlotAge:
+		return 1
+	case SlotPassphrase:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// SlotSpec describes a slot to add along with the credential that unlocks it
+type SlotSpec struct {
+	Type  SlotType
+	Label string
+	// Questions, Trustees and Threshold are used by questions slots
+	Questions Questions
+	Trustees  []string
+	Threshold int
+	// Passphrase is used by passphrase slots
+	Passphrase []byte
+	// Recipient is used by age slots
+	Recipient string
+	// Keyfile is used by keyfile slots, and is required in addition to
+	// answers by questions slots if set
+	Keyfile []byte
+}
+
+// AddSlot adds a slot to the sealed secret. The key must be the DEK, which is
+// checked against the encrypted data first. Version 1 and 2 secrets are
+// upgraded to version 3 with their share set as slot 0.
+func (s *Sealer) AddSlot(ctx context.Context, sealed, key []byte, spec SlotSpec) ([]byte, error) {
+	sealedSecret, err := Decode(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	// The data is re-encrypted when upgrading exactly as it's stored, so it
+	// isn't decoded
+	plaintext, err := decryptData(sealedSecret.Encrypted, key)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data (incorrect key?)")
+	}
+	defer plaintext.Destroy()
+
+	dek := secmem.Copy(bytes.Clone(key))
+	defer dek.Destroy()
+
+	switch sealedSecret.Version {
+	case FormatV1, FormatV2:
+		upgraded, err := s.upgrade(sealedSecret, plaintext.Bytes(), key)
+		if err != nil {
+			return nil, err
+		}
+		defer upgraded.Destroy()
+
+		dek = upgraded
+	case FormatV3:
+	default:
+		return nil, fmt.Errorf("unknown version: %s", sealedSecret.Version)
+	}
+
+	var slotID, shareID int
+	for _, slot := range sealedSecret.Slots {
+		slotID = max(slotID, slot.ID+1)
+
+		for _, share := range slot.Shares {
+			shareID = max(shareID, share.ID+1)
+		}
+	}
+
+	slot, err := s.newSlot(ctx, slotID, shareID, dek.Bytes(), spec)
+	if err != nil {
+		return nil, err
+	}
+	sealedSecret.Slots = append(sealedSecret.Slots, *slot)
+
+	return Encode(sealedSecret)
+}
+
+// upgrade converts a version 1 or 2 secret to version 3. The data is
+// re-encrypted under a new DEK which the old share set then wraps, rather than
+// wrapping the old DEK with itself.
+func (s *Sealer) upgrade(sealedSecret *SealedSecret, plaintext, key []byte) (*secmem.Buffer, error) {
+	params, err := sealedSecret.kdfParams()
+	if err != nil {
+		return nil, err
+	}
+
+	dek := secmem.New(32)
+
+	if _, err := io.ReadFull(s.options.rand, dek.Bytes()); err != nil {
+		dek.Destroy()
+		return nil, fmt.Errorf("error reading random bytes: %w", err)
+	}
+
+	slot := Slot{
+		Type:     SlotQuestions,
+		ShareSet: sealedSecret.ShareSet,
+	}
+	slot.KDF = &params
+
+	slot.WrappedKey, err = s.encryptData(dek.Bytes(), key)
+	if err != nil {
+		dek.Destroy()
+		return nil, err
+	}
+
+	sealedSecret.Encrypted, err = s.encryptData(plaintext, dek.Bytes())
+	if err != nil {
+		dek.Destroy()
+		return nil, err
+	}
+
+	sealedSecret.Version = FormatV3
+	sealedSecret.ShareSet = ShareSet{}
+	sealedSecret.Slots = []Slot{slot}
+
+	return dek, nil
+}
+
+// RemoveSlot removes a slot from the sealed secret. The key must be the DEK so
+// that only someone able to unseal can remove slots. Removing a slot doesn't
+// change the DEK, so copies of the file made before can still be unlocked by
+// it.
+func (s *Sealer) RemoveSlot(sealed, key []byte, id int) ([]byte, error) {
+	sealedSecret, err := Decode(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	if sealedSecret.Version != FormatV3 {
+		return nil, fmt.Errorf("version %s doesn't have slots", sealedSecret.Version)
+	}
+
+	plaintext, err := unsealWithKey(sealedSecret, key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext.Destroy()
+
+	idx := slices.IndexFunc(sealedSecret.Slots, func(slot Slot) bool {
+		return slot.ID == id
+	})
+	if idx < 0 {
+		return nil, fmt.Errorf("no slot with id %d", id)
+	}
+	if len(sealedSecret.Slots) == 1 {
+		return nil, ErrLastSlot
+	}
+
+	sealedSecret.Slots = slices.Delete(sealedSecret.Slots, idx, idx+1)
+
+	return Encode(sealedSecret)
+}
+
+// newSlot creates a slot wrapping the DEK. Question IDs are offset by shareID
+// to keep share IDs unique across slots.
+func (s *Sealer) newSlot(ctx context.Context, id, shareID int, dek []byte, spec SlotSpec) (*Slot, error) {
+	slot := &Slot{
+		ID:    id,
+		Type:  spec.Type,
+		Label: spec.Label,
+	}
+
+	var (
+		slotKey *secmem.Buffer
+		err     error
+	)
+
+	switch spec.Type {
+	case SlotQuestions:
+		slotKey, err = s.newQuestionsSlot(ctx, slot, shareID, spec)
+	case SlotPassphrase:
+		slotKey, err = s.newPassphraseSlot(ctx, slot, spec)
+	case SlotKeyfile:
+		if len(spec.Keyfile) == 0 {
+			return nil, fmt.Errorf("keyfile cannot be empty")
+		}
+
+		slot.Keyfile, slotKey, _, err = s.newKeyfileKey(spec.Keyfile)
+	case SlotAge:
+		slot.Recipient = spec.Recipient
+		slot.WrappedKey, err = encryptAge(dek, spec.Recipient)
+
+		return slot, err
+	default:
+		return nil, fmt.Errorf("unknown slot type: %s", spec.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer slotKey.Destroy()
+
+	slot.WrappedKey, err = s.encryptData(dek, slotKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return slot, nil
+}
+
+func (s *Sealer) newQuestionsSlot(ctx context.Context, slot *Slot, shareID int, spec SlotSpec) (*secmem.Buffer, error) {
+	if err := spec.Questions.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.options.kdfParams.Validate(); err != nil {
+		return nil, err
+	}
+
+	joined, slotKey, err := s.newShareSetKey(&slot.ShareSet, spec.Keyfile)
+	if err != nil {
+		return nil, err
+	}
+	defer joined.Destroy()
+
+	if err := s.splitShareSet(ctx, &slot.ShareSet, joined.Bytes(), spec.Questions, spec.Trustees, spec.Threshold, shareID); err != nil {
+		slotKey.Destroy()
+		return nil, err
+	}
+
+	return slotKey, nil
+}
+
+func (s *Sealer) newPassphraseSlot(ctx context.Context, slot *Slot, spec SlotSpec) (*secmem.Buffer, error) {
+	if len(spec.Passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+	if err := s.options.kdfParams.Validate(); err != nil {
+		return nil, err
+	}
+
+	salt, err := s.random(32)
+	if err != nil {
+		return nil, err
+	}
+
+	params := s.options.kdfParams
+	slot.KDF = &params
+	slot.Salt = encoding.EncodeToString(salt)
+
+	keys, err := s.deriveKeys(ctx, params, []kdfInput{{password: spec.Passphrase, salt: salt}})
+	if err != nil {
+		return nil, err
+	}
+
+	return secmem.Copy(keys[0]), nil
+}
+
+// encryptAge encrypts data to a single age recipient
+func encryptAge(data []byte, recipient string) ([]byte, error) {
+	recipients, err := age.ParseRecipients(strings.NewReader(recipient))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing recipient: %w", err)
+	}
+	if len(recipients) != 1 {
+		return nil, fmt.Errorf("expected one recipient, got %d", len(recipients))
+	}
+
+	var buf bytes.Buffer
+
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// openSlots tries each slot the credentials could unlock, cheapest first, and
+// returns the DEK from the first that works
+func (s *Sealer) openSlots(ctx context.Context, sealedSecret *SealedSecret, answers Answers) (*secmem.Buffer, error) {
+	slots := slices.Clone(sealedSecret.Slots)
+	slices.SortStableFunc(slots, func(a, b Slot) int {
+		return cmp.Compare(a.cost(), b.cost())
+	})
+
+	var errs []error
+
+	for _, slot := range slots {
+		if s.options.slot != anySlot && slot.ID != s.options.slot {
+			continue
+		}
+
+		dek, err := s.openSlot(ctx, &slot, answers)
+		if err == nil {
+			return dek, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		errs = append(errs, fmt.Errorf("slot %d: %w", slot.ID, err))
+	}
+
+	if len(errs) == 0 {
+		return nil, ErrNoSlot
+	}
+
+	return nil, fmt.Errorf("%w: %w", ErrNoSlot, errors.Join(errs...))
+}
+
+func (s *Sealer) openSlot(ctx context.Context, slot *Slot, answers Answers) (*secmem.Buffer, error) {
+	var (
+		slotKey *secmem.Buffer
+		err     error
+	)
+
+	switch slot.Type {
+	case SlotQuestions:
+		params, err := validKDFParams(slot.KDF)
+		if err != nil {
+			return nil, err
+		}
+
+		slotKey, err = s.openShareSet(ctx, &slot.ShareSet, params, answers)
+		if err != nil {
+			return nil, err
+		}
+	case SlotPassphrase:
+		slotKey, err = s.openPassphraseSlot(ctx, slot)
+	case SlotKeyfile:
+		if slot.Keyfile == nil {
+			return nil, fmt.Errorf("missing keyfile parameters")
+		}
+
+		slotKey, _, err = s.openKeyfile(slot.Keyfile)
+	case SlotAge:
+		return s.openAgeSlot(slot)
+	default:
+		return nil, fmt.Errorf("unknown slot type: %s", slot.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer slotKey.Destroy()
+
+	dek, err := decryptData(slot.WrappedKey, slotKey.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping key (incorrect credentials?)")
+	}
+
+	return dek, nil
+}
+
+func (s *Sealer) openPassphraseSlot(ctx context.Context, slot *Slot) (*secmem.Buffer, error) {
+	if len(s.options.passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase required")
+	}
+
+	params, err := validKDFParams(slot.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := encoding.DecodeString(slot.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.deriveKeys(ctx, params, []kdfInput{{password: s.options.passphrase, salt: salt}})
+	if err != nil {
+		return nil, err
+	}
+
+	return secmem.Copy(keys[0]), nil
+}
+
+func (s *Sealer) openAgeSlot(slot *Slot) (*secmem.Buffer, error) {
+	if len(s.options.identities) == 0 {
+		return nil, fmt.Errorf("age identity required")
+	}
+
+	dek, err := decryptAge(slot.WrappedKey, s.options.identities)
+	if err != nil {
+		return nil, err
+	}
+
+	return secmem.Copy(dek), nil
+}
+
+// decryptAge decrypts data encrypted to one of the identities
+func decryptAge(data []byte, identities []age.Identity) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(data), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,267 @@
+// Package sshagent generates amnesia-sealed SSH keys and serves them over the
+// ssh-agent protocol, so the private key is only ever held in memory.
+package sshagent
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var errUnsupported = errors.New("not supported by the amnesia agent")
+
+// Key is a sealed SSH key and its public half
+type Key struct {
+	sealedSecret []byte
+	public       ssh.PublicKey
+	comment      string
+}
+
+// Sealed returns the sealed private key
+func (k Key) Sealed() []byte {
+	return k.sealedSecret
+}
+
+// AuthorizedKey returns the public key as an authorized_keys line
+func (k Key) AuthorizedKey() string {
+	line := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(k.public)), "\n")
+	if k.comment != "" {
+		line += " " + k.comment
+	}
+	return line
+}
+
+// GenerateKey generates an ed25519 SSH key and seals it in OpenSSH format
+func GenerateKey(ctx context.Context, comment string, opts ...interactive.Option) (Key, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, err
+	}
+	defer secmem.Wipe(private)
+
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return Key{}, err
+	}
+
+	secret := pem.EncodeToMemory(block)
+	defer secmem.Wipe(secret)
+	secmem.Wipe(block.Bytes)
+
+	sealed, err := interactive.Seal(ctx, secret, opts...)
+	if err != nil {
+		return Key{}, err
+	}
+
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		sealedSecret: sealed,
+		public:       sshPublic,
+		comment:      comment,
+	}, nil
+}
+
+// ConfirmFunc is asked before each signature and returns whether to allow it
+type ConfirmFunc func(comment string, public ssh.PublicKey) (bool, error)
+
+type AgentOption func(*Agent)
+
+// WithConfirm asks confirm before each use of the key
+func WithConfirm(confirm ConfirmFunc) AgentOption {
+	return func(a *Agent) {
+		a.confirm = confirm
+	}
+}
+
+// Agent holds a single ed25519 key and implements the ssh-agent protocol.
+// Keys can't be added to or removed from it.
+type Agent struct {
+	mu      sync.Mutex
+	seed    *secmem.Buffer
+	public  ssh.PublicKey
+	comment string
+	confirm ConfirmFunc
+}
+
+var _ agent.ExtendedAgent = (*Agent)(nil)
+
+// NewAgent creates an agent for the unsealed OpenSSH private key. The key's
+// seed is copied into locked memory until the agent is closed.
+func NewAgent(privateKey []byte, comment string, opts ...AgentOption) (*Agent, error) {
+	raw, err := ssh.ParseRawPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing SSH key: %w", err)
+	}
+
+	key, ok := raw.(*ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("only ed25519 SSH keys are supported")
+	}
+
+	defer secmem.Wipe(*key)
+
+	public, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Agent{
+		seed:    secmem.Copy(key.Seed()),
+		public:  public,
+		comment: comment,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a, nil
+}
+
+// PublicKey returns the public half of the agent's key
+func (a *Agent) PublicKey() ssh.PublicKey {
+	return a.public
+}
+
+// Close destroys the key. The agent lists no keys afterwards.
+func (a *Agent) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.seed.Destroy()
+}
+
+// Serve serves the agent on the listener until ctx is done, then closes the
+// listener and the agent
+func (a *Agent) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	defer a.Close()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			done := make(chan struct{})
+			defer close(done)
+
+			go func() {
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}()
+
+			agent.ServeAgent(a, conn)
+		}()
+	}
+}
+
+func (a *Agent) List() ([]*agent.Key, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.seed.Len() == 0 {
+		return nil, nil
+	}
+
+	return []*agent.Key{{
+		Format:  a.public.Type(),
+		Blob:    a.public.Marshal(),
+		Comment: a.comment,
+	}}, nil
+}
+
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+func (a *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	// Signing is serialised so confirmation prompts don't overlap
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.seed.Len() == 0 || !bytes.Equal(key.Marshal(), a.public.Marshal()) {
+		return nil, fmt.Errorf("key not found")
+	}
+
+	if a.confirm != nil {
+		ok, err := a.confirm(a.comment, key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("signature refused")
+		}
+	}
+
+	// The expanded key lives on the Go heap only while signing, as crypto/ed25519
+	// can't use keys in locked memory
+	private := ed25519.NewKeyFromSeed(a.seed.Bytes())
+	defer secmem.Wipe(private)
+
+	return &ssh.Signature{
+		Format: ssh.KeyAlgoED25519,
+		Blob:   ed25519.Sign(private, data),
+	}, nil
+}
+
+func (a *Agent) Add(key agent.AddedKey) error {
+	return errUnsupported
+}
+
+func (a *Agent) Remove(key ssh.PublicKey) error {
+	return errUnsupported
+}
+
+func (a *Agent) RemoveAll() error {
+	return errUnsupported
+}
+
+func (a *Agent) Lock(passphrase []byte) error {
+	return errUnsupported
+}
+
+func (a *Agent) Unlock(passphrase []byte) error {
+	return errUnsupported
+}
+
+func (a *Agent) Signers() ([]ssh.Signer, error) {
+	return nil, errUnsupported
+}
+
+func (a *Agent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
@@ -0,0 +1,121 @@
+package sshagent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func testKey(t *testing.T) []byte {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	block, err := ssh.MarshalPrivateKey(private, "test")
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(block)
+}
+
+func testClient(t *testing.T, a *Agent) agent.ExtendedAgent {
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+
+	go agent.ServeAgent(a, server)
+
+	return agent.NewClient(client)
+}
+
+func TestAgent(t *testing.T) {
+	a, err := NewAgent(testKey(t), "key.json")
+	assert.NoError(t, err)
+
+	client := testClient(t, a)
+
+	keys, err := client.List()
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "key.json", keys[0].Comment)
+
+	signature, err := client.Sign(keys[0], []byte("data"))
+	assert.NoError(t, err)
+	assert.NoError(t, a.PublicKey().Verify([]byte("data"), signature))
+
+	t.Run("Unsupported", func(t *testing.T) {
+		assert.Error(t, client.RemoveAll())
+		assert.Error(t, client.Lock([]byte("passphrase")))
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		other, err := NewAgent(testKey(t), "other.json")
+		assert.NoError(t, err)
+
+		_, err = client.Sign(other.PublicKey(), []byte("data"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		a.Close()
+
+		keys, err := client.List()
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+
+		_, err = client.Sign(a.PublicKey(), []byte("data"))
+		assert.Error(t, err)
+	})
+}
+
+func TestConfirm(t *testing.T) {
+	allow := false
+
+	a, err := NewAgent(testKey(t), "key.json", WithConfirm(func(comment string, public ssh.PublicKey) (bool, error) {
+		assert.Equal(t, "key.json", comment)
+		return allow, nil
+	}))
+	assert.NoError(t, err)
+
+	client := testClient(t, a)
+
+	_, err = client.Sign(a.PublicKey(), []byte("data"))
+	assert.Error(t, err)
+
+	allow = true
+
+	_, err = client.Sign(a.PublicKey(), []byte("data"))
+	assert.NoError(t, err)
+}
+
+func TestServe(t *testing.T) {
+	a, err := NewAgent(testKey(t), "key.json")
+	assert.NoError(t, err)
+
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "agent.sock"))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error)
+	go func() { served <- a.Serve(ctx, listener) }()
+
+	conn, err := net.Dial("unix", listener.Addr().String())
+	assert.NoError(t, err)
+
+	keys, err := agent.NewClient(conn).List()
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	cancel()
+	assert.NoError(t, <-served)
+
+	keys, err = a.List()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -0,0 +1,118 @@
+package amnesia
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+var ErrUnsupportedTrustees = fmt.Errorf("format version %s doesn't support trustees", FormatV1)
+
+// TrusteeShare is a share decrypted by its trustee, or by a participant in a
+// ceremony. It can be handed back to the owner and given in place of an answer
+// when unsealing.
+type TrusteeShare struct {
+	ID    int    `json:"id"`
+	Share []byte `json:"share"`
+}
+
+func ParseTrusteeShare(data []byte) (*TrusteeShare, error) {
+	var share TrusteeShare
+
+	if err := json.Unmarshal(data, &share); err != nil {
+		return nil, fmt.Errorf("error parsing trustee share: %w", err)
+	}
+	if len(share.Share) < 2 {
+		return nil, fmt.Errorf("trustee share too short")
+	}
+
+	return &share, nil
+}
+
+func (t *TrusteeShare) Encode() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Wipe zeroes the share
+func (t *TrusteeShare) Wipe() {
+	secmem.Wipe(t.Share)
+}
+
+// DecryptTrusteeShare decrypts the first trustee share in the sealed secret
+// that one of the identities can decrypt
+func DecryptTrusteeShare(sealedSecret *SealedSecret, identities ...age.Identity) (*TrusteeShare, error) {
+	for _, set := range sealedSecret.shareSets() {
+		for _, share := range set.Shares {
+			if !share.IsTrustee() {
+				continue
+			}
+
+			decrypted, err := share.DecryptTrustee(identities...)
+			if err != nil {
+				return nil, err
+			}
+			if decrypted != nil {
+				return decrypted, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no trustee share for the given identities")
+}
+
+// DecryptTrustee decrypts a trustee share, returning nil if none of the
+// identities match its recipient
+func (s *Share) DecryptTrustee(identities ...age.Identity) (*TrusteeShare, error) {
+	if !s.IsTrustee() {
+		return nil, fmt.Errorf("share %d isn't a trustee share", s.ID)
+	}
+
+	ciphertext, err := encoding.DecodeString(s.Share)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := decryptAge(ciphertext, identities)
+
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting trustee share %d: %w", s.ID, err)
+	}
+
+	return &TrusteeShare{ID: s.ID, Share: decrypted}, nil
+}
+
+// decryptedShare returns a copy of the given decrypted share with the ID, or
+// nil if there isn't one
+func (s *Sealer) decryptedShare(id int) []byte {
+	idx := slices.IndexFunc(s.options.trusteeShares, func(t *TrusteeShare) bool {
+		return t.ID == id
+	})
+	if idx < 0 {
+		return nil
+	}
+
+	return slices.Clone(s.options.trusteeShares[idx].Share)
+}
+
+// trusteeShare decrypts a trustee share with the given identities, returning
+// nil if none match
+func (s *Sealer) trusteeShare(share *Share) ([]byte, error) {
+	if len(s.options.identities) == 0 {
+		return nil, nil
+	}
+
+	decrypted, err := share.DecryptTrustee(s.options.identities...)
+	if err != nil || decrypted == nil {
+		return nil, err
+	}
+
+	return decrypted.Share, nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/ageplugin"
+)
+
+type ageIdentityCmd struct {
+	IdentityFile string `arg:"" help:"Identity file to convert, or - for stdin." default:"-"`
+	Embed        bool   `help:"Embed the sealed identity." xor:"form" required:""`
+	ByReference  bool   `help:"Refer to the sealed identity by path." xor:"form" required:""`
+	Hash         bool   `help:"Refer to the sealed identity by hash." xor:"form" required:""`
+	Sealed       string `help:"File to write the sealed identity to when converting to a reference. Defaults to the identity store." type:"path"`
+	OutputFile   string `help:"File to write the converted identity to." short:"o" name:"output"`
+}
+
+func (a *ageIdentityCmd) Help() string {
+	return `Convert an amnesia age identity between the embedded and reference forms (experimental)
+
+Identities embedding the sealed identity are converted by writing it to its own file and referring to it. Referring identities are converted by reading the file they refer to. Other lines in the identity file are kept.
+
+Examples:
+  amnesia age-identity --by-reference --sealed ~/sealed-identity.json -o short.txt identity.txt
+  amnesia age-identity --hash -o short.txt identity.txt
+  amnesia age-identity --embed -o identity.txt short.txt`
+}
+
+func (a *ageIdentityCmd) Run(ctx *kong.Context) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	if a.IdentityFile == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(a.IdentityFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	var (
+		out       bytes.Buffer
+		converted int
+	)
+
+	for line := range strings.Lines(string(data)) {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "AGE-PLUGIN-AMNESIA-1") {
+			out.WriteString(line)
+			continue
+		}
+
+		identity, err := a.convert(trimmed)
+		if err != nil {
+			return err
+		}
+
+		out.WriteString(identity + "\n")
+		converted++
+	}
+
+	if converted == 0 {
+		return fmt.Errorf("no amnesia identities found")
+	}
+
+	return writeOutput(a.OutputFile, out.Bytes())
+}
+
+func (a *ageIdentityCmd) convert(identity string) (string, error) {
+	sealed, err := ageplugin.ResolveIdentity(identity)
+	if err != nil {
+		return "", err
+	}
+
+	if a.Embed {
+		return ageplugin.EmbeddedIdentity(sealed), nil
+	}
+
+	encoded, path, err := referenceIdentity(sealed, a.Sealed, a.Hash)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(os.Stderr, "Sealed identity written to %s\n", path)
+
+	return encoded, nil
+}
@@ -9,11 +9,18 @@ import (
 	"github.com/alecthomas/kong"
 	"github.com/cedws/amnesia/pkg/amnesia/ageplugin"
 	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 )
 
 type ageKeygenCmd struct {
-	OutputFile string `help:"File to write the identity to." short:"o" name:"output"`
-	NoTest     bool   `help:"Don't prompt for test questions." short:"t"`
+	OutputFile  string `help:"File to write the identity to." short:"o" name:"output"`
+	NoTest      bool   `help:"Don't prompt for test questions." short:"t"`
+	Keyfile     string `help:"Require the contents of this file in addition to answers." short:"k" type:"existingfile"`
+	ByReference bool   `help:"Write a short identity referring to the sealed identity file instead of embedding it."`
+	Hash        bool   `help:"Refer to the sealed identity by hash rather than path, implies --by-reference."`
+	Sealed      string `help:"File to write the sealed identity to with --by-reference. Defaults to the identity store." type:"path"`
+	Import      string `help:"Seal the identities in this age identity file instead of generating one." type:"existingfile" xor:"kind"`
+	PQ          bool   `help:"Generate a post-quantum hybrid identity." name:"pq" xor:"kind"`
 }
 
 func (s *ageKeygenCmd) Help() string {
@@ -21,13 +28,27 @@ func (s *ageKeygenCmd) Help() string {
 
 This command generates an amnesia-sealed age X25519 identity which can be used with age when amnesia is installed as a plugin.
 
+With --pq, a post-quantum hybrid ML-KEM-768 + X25519 identity is generated instead. Its recipient is much longer, but files encrypted to it stay safe from a future quantum computer.
+
+With --import, the existing X25519, hybrid or plugin identities in an identity file are sealed instead, so files already encrypted to their recipients can be decrypted with questions. Several identities are sealed together as a keyring. Once sealed, the original identity file should be securely deleted.
+
+By default the whole sealed identity is embedded in the identity, making it several kilobytes long. With --by-reference, the sealed identity is written to its own file and the identity refers to it by path, so it stays short and changes to the sealed file such as new questions don't invalidate copies of it.
+
+With --hash, the identity refers to the sealed identity by its hash instead, and it's looked up in the identity store and AMNESIA_IDENTITY_PATH, so the sealed file can be moved. Any change to the sealed file changes its hash, so after changing it the identity has to be replaced too, as age-rekey and age-keyring do.
+
 Examples:
   amnesia age-keygen
-  amnesia age-keygen -o identity.txt`
+  amnesia age-keygen -o identity.txt
+  amnesia age-keygen --by-reference --sealed ~/sealed-identity.json -o identity.txt
+  amnesia age-keygen --hash -o identity.txt
+  amnesia age-keygen --pq -o identity.txt
+  amnesia age-keygen --import key.txt -o identity.txt`
 }
 
-func (s *ageKeygenCmd) interactiveOpts() []interactive.Option {
-	var opts []interactive.Option
+func (s *ageKeygenCmd) interactiveOpts(keyfile []byte) []interactive.Option {
+	opts := []interactive.Option{
+		interactive.WithKeyfile(keyfile),
+	}
 
 	if !s.NoTest {
 		opts = append(opts, interactive.WithTestQuestions())
@@ -37,24 +58,93 @@ func (s *ageKeygenCmd) interactiveOpts() []interactive.Option {
 }
 
 func (s *ageKeygenCmd) Run(ctx *kong.Context) error {
-	identity, err := ageplugin.GenerateIdentity(context.Background(), s.interactiveOpts()...)
+	keyfile, err := readKeyfile(s.Keyfile)
 	if err != nil {
 		return err
 	}
+	defer secmem.Wipe(keyfile)
+
+	identity, err := s.identity(keyfile)
+	if err != nil {
+		return err
+	}
+
+	encoded := identity.Identity()
+
+	if s.ByReference || s.Hash {
+		var path string
+
+		encoded, path, err = referenceIdentity(identity.Sealed(), s.Sealed, s.Hash)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Sealed identity written to %s\n", path)
+	}
 
 	if s.OutputFile != "" {
-		if err := os.WriteFile(s.OutputFile, []byte(identity.Identity()), 0600); err != nil {
+		if err := os.WriteFile(s.OutputFile, []byte(encoded), 0600); err != nil {
 			return err
 		}
 
-		fmt.Printf("Public key: %s\n", identity.Recipient())
+		if identity.Recipient() != "" {
+			fmt.Printf("Public key: %s\n", identity.Recipient())
+		}
 
 		return nil
 	}
 
 	fmt.Printf("# created %s\n", time.Now().Format(time.RFC3339))
-	fmt.Printf("# public key: %s\n", identity.Recipient())
-	fmt.Println(identity.Identity())
+	if identity.Recipient() != "" {
+		fmt.Printf("# public key: %s\n", identity.Recipient())
+	}
+	fmt.Println(encoded)
 
 	return nil
 }
+
+// identity generates a new identity, or seals the imported one
+func (s *ageKeygenCmd) identity(keyfile []byte) (ageplugin.Identity, error) {
+	if s.PQ {
+		return ageplugin.GenerateHybridIdentity(context.Background(), s.interactiveOpts(keyfile)...)
+	}
+	if s.Import == "" {
+		return ageplugin.GenerateIdentity(context.Background(), s.interactiveOpts(keyfile)...)
+	}
+
+	data, err := os.ReadFile(s.Import)
+	if err != nil {
+		return ageplugin.Identity{}, err
+	}
+	defer secmem.Wipe(data)
+
+	keyring, err := ageplugin.ParseKeyring(data)
+	if err != nil {
+		return ageplugin.Identity{}, fmt.Errorf("error reading %s: %w", s.Import, err)
+	}
+
+	return ageplugin.ImportIdentity(context.Background(), keyring, s.interactiveOpts(keyfile)...)
+}
+
+// referenceIdentity stores the sealed identity and returns an identity
+// referring to it, along with where it was stored
+func referenceIdentity(sealed []byte, path string, byHash bool) (string, string, error) {
+	stored, err := ageplugin.StoreSealed(path, sealed)
+	if err != nil {
+		return "", "", err
+	}
+
+	ref := ageplugin.HashReference(sealed)
+	if !byHash {
+		if ref, err = ageplugin.PathReference(stored); err != nil {
+			return "", "", err
+		}
+	}
+
+	identity, err := ref.Identity()
+	if err != nil {
+		return "", "", err
+	}
+
+	return identity, stored, nil
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/ageplugin"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+type ageKeyringCmd struct {
+	List   ageKeyringListCmd   `cmd:""`
+	Add    ageKeyringAddCmd    `cmd:""`
+	Remove ageKeyringRemoveCmd `cmd:""`
+}
+
+func (a *ageKeyringCmd) Help() string {
+	return `Manage the identities sealed in an amnesia age identity (experimental)
+
+An amnesia identity can hold a keyring of several age identities, so files encrypted to old identities can still be decrypted after rotating to a new one. When decrypting, the questions are answered once and each identity is tried in turn. Adding or removing identities keeps the questions the same.`
+}
+
+type ageKeyringListCmd struct {
+	File        string `help:"Identity file to list the keyring of." short:"f" required:"" type:"existingfile"`
+	unlockFlags `embed:""`
+}
+
+func (l *ageKeyringListCmd) Help() string {
+	return `List the identities in the keyring of an amnesia identity, oldest first.
+
+Examples:
+  amnesia age-keyring list -f identity.txt`
+}
+
+func (l *ageKeyringListCmd) Run(ctx *kong.Context) error {
+	data, err := os.ReadFile(l.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := l.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	identities := amnesiaIdentities(data)
+	if len(identities) == 0 {
+		return fmt.Errorf("no amnesia identities found")
+	}
+
+	for idx, identity := range identities {
+		sealed, err := ageplugin.ResolveIdentity(identity)
+		if err != nil {
+			return err
+		}
+
+		keyring, err := ageplugin.UnsealKeyring(context.Background(), sealed, opts...)
+		if err != nil {
+			return err
+		}
+
+		if idx > 0 {
+			fmt.Println()
+		}
+
+		for id, entry := range keyring.Entries() {
+			recipient := entry.Recipient
+			if recipient == "" {
+				recipient = "(recipient unknown)"
+			}
+
+			fmt.Printf("%d\t%s\t%s\n", id+1, entry.Type, recipient)
+		}
+	}
+
+	return nil
+}
+
+// amnesiaIdentities returns the amnesia identities in an identity file
+func amnesiaIdentities(data []byte) []string {
+	var identities []string
+
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "AGE-PLUGIN-AMNESIA-1") {
+			identities = append(identities, line)
+		}
+	}
+
+	return identities
+}
+
+type ageKeyringAddCmd struct {
+	File        string `help:"Identity file to add an identity to." short:"f" required:"" type:"existingfile"`
+	OutputFile  string `help:"File to write the updated identity to." short:"o" name:"output"`
+	PQ          bool   `help:"Generate a post-quantum hybrid identity." name:"pq" xor:"kind"`
+	Import      string `help:"Add the identities in this age identity file instead of generating one." type:"existingfile" xor:"kind"`
+	Sealed      string `help:"File to write the sealed identity to for identities referring to it by hash. Defaults to the identity store." type:"path"`
+	unlockFlags `embed:""`
+}
+
+func (a *ageKeyringAddCmd) Help() string {
+	return `Add an identity to the keyring of an amnesia identity.
+
+A new X25519 identity is generated by default, and becomes the newest identity whose public key is printed. Encrypt new files to it, and keep the older identities to decrypt old files.
+
+Examples:
+  amnesia age-keyring add -f identity.txt -o identity.txt
+  amnesia age-keyring add -f identity.txt -o identity.txt --pq
+  amnesia age-keyring add -f identity.txt -o identity.txt --import old-key.txt`
+}
+
+func (a *ageKeyringAddCmd) Run(ctx *kong.Context) error {
+	data, err := os.ReadFile(a.File)
+	if err != nil {
+		return err
+	}
+
+	added, err := a.identities()
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := a.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	out, replaced, err := updateIdentities(data, a.Sealed, func(sealed []byte) (ageplugin.Identity, error) {
+		return ageplugin.EditKeyring(context.Background(), sealed, func(keyring ageplugin.Keyring) (ageplugin.Keyring, error) {
+			for _, identity := range added {
+				var err error
+				if keyring, err = keyring.Add(identity); err != nil {
+					return nil, err
+				}
+			}
+
+			return keyring, nil
+		}, opts...)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeOutput(a.OutputFile, out); err != nil {
+		return err
+	}
+
+	return removeReplaced(replaced)
+}
+
+// identities generates the identity to add, or reads the imported ones
+func (a *ageKeyringAddCmd) identities() (ageplugin.Keyring, error) {
+	switch {
+	case a.Import != "":
+		data, err := os.ReadFile(a.Import)
+		if err != nil {
+			return nil, err
+		}
+		defer secmem.Wipe(data)
+
+		keyring, err := ageplugin.ParseKeyring(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", a.Import, err)
+		}
+
+		return keyring, nil
+	case a.PQ:
+		identity, err := age.GenerateHybridIdentity()
+		if err != nil {
+			return nil, err
+		}
+
+		return ageplugin.Keyring{identity.String()}, nil
+	default:
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			return nil, err
+		}
+
+		return ageplugin.Keyring{identity.String()}, nil
+	}
+}
+
+type ageKeyringRemoveCmd struct {
+	File        string `help:"Identity file to remove an identity from." short:"f" required:"" type:"existingfile"`
+	OutputFile  string `help:"File to write the updated identity to." short:"o" name:"output"`
+	ID          int    `arg:"" help:"Number of the identity to remove, as listed by age-keyring list."`
+	Sealed      string `help:"File to write the sealed identity to for identities referring to it by hash. Defaults to the identity store." type:"path"`
+	unlockFlags `embed:""`
+}
+
+func (r *ageKeyringRemoveCmd) Help() string {
+	return `Remove an identity from the keyring of an amnesia identity.
+
+Files encrypted only to the removed identity can no longer be decrypted with it. For identities referring to the sealed identity by hash, the old sealed identity still holds the removed identity, so it's removed from the identity store, or its path printed if it's elsewhere.
+
+Examples:
+  amnesia age-keyring remove -f identity.txt -o identity.txt 1`
+}
+
+func (r *ageKeyringRemoveCmd) Run(ctx *kong.Context) error {
+	data, err := os.ReadFile(r.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := r.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	out, replaced, err := updateIdentities(data, r.Sealed, func(sealed []byte) (ageplugin.Identity, error) {
+		return ageplugin.EditKeyring(context.Background(), sealed, func(keyring ageplugin.Keyring) (ageplugin.Keyring, error) {
+			return keyring.Remove(r.ID - 1)
+		}, opts...)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeOutput(r.OutputFile, out); err != nil {
+		return err
+	}
+
+	return removeReplaced(replaced)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/ageplugin"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+)
+
+type ageRecipientCmd struct {
+	File       string `help:"Sealed file to take the questions and threshold from. Prompted for if not given." short:"f" type:"existingfile"`
+	OutputFile string `help:"File to write the recipient to." short:"o" name:"output"`
+}
+
+func (a *ageRecipientCmd) Help() string {
+	return `Create an age recipient from a set of questions (experimental)
+
+Files encrypted to the recipient hold their file key sealed with the questions, so they can be decrypted with answers alone, without an identity. The answers are asked for when encrypting. The recipient only holds the questions and threshold, and can be shared.
+
+Examples:
+  amnesia age-recipient -o recipient.txt
+  amnesia age-recipient -f sealed.json -o recipient.txt
+  age -R recipient.txt -o secret.age secret.txt
+  age -d -j amnesia secret.age`
+}
+
+func (a *ageRecipientCmd) Run(ctx *kong.Context) error {
+	var template ageplugin.Template
+
+	if a.File != "" {
+		sealed, err := os.ReadFile(a.File)
+		if err != nil {
+			return err
+		}
+
+		if template, err = ageplugin.TemplateFromSealed(sealed); err != nil {
+			return err
+		}
+	} else {
+		questions, threshold, err := interactive.PromptForTemplate(context.Background())
+		if err != nil {
+			return err
+		}
+
+		template = ageplugin.Template{Threshold: threshold, Questions: questions}
+	}
+
+	recipient, err := template.Recipient()
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(a.OutputFile, []byte(fmt.Sprintf("# %d of %d questions required\n%s\n", template.Threshold, len(template.Questions), recipient)))
+}
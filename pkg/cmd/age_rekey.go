@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/ageplugin"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+type ageRekeyCmd struct {
+	IdentityFile string `arg:"" help:"Identity file to change the questions of." type:"existingfile"`
+	OutputFile   string `help:"File to write the identity to." short:"o" name:"output"`
+	NoTest       bool   `help:"Don't prompt for test questions." short:"t"`
+	NewKeyfile   string `help:"Require the contents of this file in addition to the new answers." type:"existingfile"`
+	Sealed       string `help:"File to write the sealed identity to for identities referring to it by hash. Defaults to the identity store." type:"path"`
+	unlockFlags  `embed:""`
+}
+
+func (a *ageRekeyCmd) Help() string {
+	return `Change the questions of an amnesia age identity (experimental)
+
+The identity is unsealed and sealed again with new questions. The age identity inside doesn't change, so neither does its recipient and files already encrypted to it can still be decrypted.
+
+Identities embedding the sealed identity are written out with the new one embedded. For identities referring to it by path, the referenced file is overwritten and the identity stays the same. For identities referring to it by hash, the new sealed identity is stored and the identity refers to its new hash. The old sealed identity can still be unsealed with the old answers, so once the identity is written it's removed from the identity store, or its path printed if it's elsewhere. Other copies of the identity referring to the old hash stop working.
+
+Examples:
+  amnesia age-rekey -o identity.txt identity.txt
+  amnesia age-rekey --new-keyfile /media/usb/keyfile -o identity.txt identity.txt`
+}
+
+func (a *ageRekeyCmd) Run(ctx *kong.Context) error {
+	data, err := os.ReadFile(a.IdentityFile)
+	if err != nil {
+		return err
+	}
+
+	unlockOpts, wipe, err := a.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	newKeyfile, err := readKeyfile(a.NewKeyfile)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(newKeyfile)
+
+	sealOpts := []interactive.Option{interactive.WithKeyfile(newKeyfile)}
+	if !a.NoTest {
+		sealOpts = append(sealOpts, interactive.WithTestQuestions())
+	}
+
+	out, replaced, err := updateIdentities(data, a.Sealed, func(sealed []byte) (ageplugin.Identity, error) {
+		return ageplugin.RekeyIdentity(context.Background(), sealed, unlockOpts, sealOpts)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeOutput(a.OutputFile, out); err != nil {
+		return err
+	}
+
+	return removeReplaced(replaced)
+}
+
+// updateIdentities replaces the sealed identity of each amnesia identity in
+// an identity file with the one returned by update, keeping other lines.
+// Identities embedding it are replaced, and ones referring to it by hash refer
+// to the new one stored at sealedPath. For ones referring to it by path, the
+// referenced file is overwritten and the identity kept. The hash references
+// replaced are returned, to remove the old sealed identities once the new
+// identities are written.
+func updateIdentities(data []byte, sealedPath string, update func(sealed []byte) (ageplugin.Identity, error)) ([]byte, []ageplugin.Reference, error) {
+	var (
+		out      bytes.Buffer
+		updated  int
+		replaced []ageplugin.Reference
+	)
+
+	for line := range strings.Lines(string(data)) {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "AGE-PLUGIN-AMNESIA-1") {
+			out.WriteString(line)
+			continue
+		}
+
+		ref, err := ageplugin.IdentityReference(trimmed)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		identity, err := updateIdentity(trimmed, ref, sealedPath, update)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ref != nil && ref.SHA256 != "" && !slices.Contains(replaced, *ref) {
+			replaced = append(replaced, *ref)
+		}
+
+		out.WriteString(identity + "\n")
+		updated++
+	}
+
+	if updated == 0 {
+		return nil, nil, fmt.Errorf("no amnesia identities found")
+	}
+
+	return out.Bytes(), replaced, nil
+}
+
+func updateIdentity(identity string, ref *ageplugin.Reference, sealedPath string, update func(sealed []byte) (ageplugin.Identity, error)) (string, error) {
+
+	sealed, err := ageplugin.ResolveIdentity(identity)
+	if err != nil {
+		return "", err
+	}
+
+	updated, err := update(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	if recipient := updated.Recipient(); recipient != "" {
+		fmt.Fprintf(os.Stderr, "Public key: %s\n", recipient)
+	}
+
+	switch {
+	case ref == nil:
+		return updated.Identity(), nil
+	case ref.Path != "":
+		if err := writeFileAtomic(ref.Path, updated.Sealed()); err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(os.Stderr, "Sealed identity written to %s\n", ref.Path)
+
+		return identity, nil
+	default:
+		encoded, path, err := referenceIdentity(updated.Sealed(), sealedPath, true)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(os.Stderr, "Sealed identity written to %s\n", path)
+
+		return encoded, nil
+	}
+}
+
+// removeReplaced removes the sealed identities replaced hash references
+// referred to from the identity store, as they can still be unsealed with the
+// old answers. Ones elsewhere are pointed out to be deleted by hand.
+func removeReplaced(replaced []ageplugin.Reference) error {
+	for _, ref := range replaced {
+		path, removed, err := ageplugin.RemoveStored(ref)
+		if err != nil {
+			return fmt.Errorf("error removing old sealed identity: %w", err)
+		}
+
+		if removed {
+			fmt.Fprintf(os.Stderr, "Old sealed identity removed from %s\n", path)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: the old sealed identity at %s can still be unsealed, delete it if it's no longer needed\n", path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/agent"
+)
+
+type agentCmd struct {
+	Serve  agentServeCmd  `cmd:"" default:"withargs" help:"Run the agent."`
+	Forget agentForgetCmd `cmd:"" help:"Make the running agent forget keys."`
+}
+
+func (a *agentCmd) Help() string {
+	return `Remember unlocked keys for a while, like ssh-agent.
+
+While AMNESIA_AGENT_SOCK is set, commands and the age plugin give the agent the key of each sealed file they unlock, and use the keys it remembers instead of asking questions again. Keys are held in locked memory and forgotten once unused for the idle timeout, or when the agent stops. Only processes run by the same user can connect.
+
+Examples:
+  amnesia agent
+  amnesia agent --idle-timeout 5m -a ~/.amnesia.sock
+  amnesia agent forget -f sealed.json
+  amnesia agent forget --all`
+}
+
+type agentServeCmd struct {
+	Socket      string        `help:"Path of the agent socket. Defaults to a new temporary directory." short:"a" type:"path"`
+	IdleTimeout time.Duration `help:"Forget keys unused for this long." default:"15m"`
+}
+
+func (s *agentServeCmd) AfterApply() error {
+	if s.IdleTimeout <= 0 {
+		return fmt.Errorf("idle timeout must be positive")
+	}
+
+	return nil
+}
+
+func (s *agentServeCmd) Run(ctx *kong.Context) error {
+	listener, cleanup, err := listenUnix(s.Socket, "amnesia-agent-")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	serveCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Printf("%s=%s; export %s;\n", agent.SocketEnv, listener.Addr(), agent.SocketEnv)
+	fmt.Fprintln(os.Stderr, "Agent running, interrupt to stop")
+
+	if err := agent.New(s.IdleTimeout).Serve(serveCtx, listener); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Agent stopped, keys removed from memory")
+
+	return nil
+}
+
+type agentForgetCmd struct {
+	File string `help:"Sealed file to forget the key of." short:"f" type:"existingfile" xor:"forget" required:""`
+	All  bool   `help:"Forget every key." xor:"forget" required:""`
+}
+
+func (f *agentForgetCmd) Run(ctx *kong.Context) error {
+	client := agent.FromEnv()
+	if client == nil {
+		return fmt.Errorf("%s is not set", agent.SocketEnv)
+	}
+
+	if f.All {
+		return client.Clear()
+	}
+
+	sealed, err := os.ReadFile(f.File)
+	if err != nil {
+		return err
+	}
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	if err != nil {
+		return err
+	}
+
+	return client.Forget(sealedSecret.Fingerprint())
+}
+
+// listenUnix listens on a socket only the current user can connect to, in a
+// new temporary directory if path is empty. The returned func removes it.
+func listenUnix(path, tempPrefix string) (*net.UnixListener, func(), error) {
+	cleanup := func() { os.Remove(path) }
+
+	if path == "" {
+		dir, err := os.MkdirTemp("", tempPrefix)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		path = filepath.Join(dir, "agent.sock")
+		cleanup = func() { os.RemoveAll(dir) }
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		cleanup()
+		return nil, nil, err
+	}
+
+	return listener, cleanup, nil
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+)
+
+type ceremonyCmd struct {
+	Contribute ceremonyContributeCmd `cmd:""`
+	Combine    ceremonyCombineCmd    `cmd:""`
+}
+
+func (c *ceremonyCmd) Help() string {
+	return `Commands for unsealing a secret with several participants, each answering their own questions on their own machine.`
+}
+
+type ceremonyContributeCmd struct {
+	File       string `help:"Sealed file to contribute to." short:"f" required:"" type:"existingfile"`
+	Recipient  string `help:"age recipient of the coordinator." short:"r" required:""`
+	OutputFile string `help:"File to write the contribution to." short:"o"`
+}
+
+func (c *ceremonyContributeCmd) Help() string {
+	return `Contribute answers to an unseal ceremony.
+
+Only the questions you answer are used. Your answers never leave your machine, the decrypted shares are encrypted to the coordinator, who combines them with other participants' contributions.
+
+Examples:
+  amnesia ceremony contribute -f sealed.json -r age1... -o alice.age`
+}
+
+func (c *ceremonyContributeCmd) AfterApply() error {
+	if _, err := age.ParseRecipients(strings.NewReader(c.Recipient)); err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ceremonyContributeCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(c.File)
+	if err != nil {
+		return err
+	}
+
+	contribution, err := interactive.Contribute(context.Background(), sealed, c.Recipient)
+	if err != nil {
+		return fmt.Errorf("failed to contribute: %w", err)
+	}
+
+	return writeOutput(c.OutputFile, contribution)
+}
+
+type ceremonyCombineCmd struct {
+	File          string   `help:"Sealed file to unseal." short:"f" required:"" type:"existingfile"`
+	OutputFile    string   `help:"File to write unsealed secret to." short:"o"`
+	Contributions []string `arg:"" help:"Contribution files from participants." type:"existingfile"`
+	unlockFlags   `embed:""`
+}
+
+func (c *ceremonyCombineCmd) Help() string {
+	return `Combine contributions to an unseal ceremony and unseal the secret.
+
+The coordinator's age identity is given with -i. If the contributions don't reach the threshold, the remaining questions can be answered interactively.
+
+Examples:
+  amnesia ceremony combine -f sealed.json -i coordinator.txt alice.age bob.age`
+}
+
+func (c *ceremonyCombineCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(c.File)
+	if err != nil {
+		return err
+	}
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	if err != nil {
+		return err
+	}
+
+	identities, err := readIdentities(c.Identity)
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 {
+		return fmt.Errorf("coordinator identity is required")
+	}
+
+	var contributions [][]byte
+	for _, path := range c.Contributions {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		contributions = append(contributions, data)
+	}
+
+	shares, err := amnesia.OpenContributions(sealedSecret, contributions, identities...)
+	if err != nil {
+		return fmt.Errorf("failed to open contributions: %w", err)
+	}
+	defer func() {
+		for _, share := range shares {
+			share.Wipe()
+		}
+	}()
+
+	opts, wipe, err := c.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	opts = append(opts, interactive.WithTrusteeShares(shares...))
+
+	unsealed, err := interactive.Unseal(context.Background(), sealed, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to unseal secret: %w", err)
+	}
+	defer unsealed.Destroy()
+
+	return writeOutput(c.OutputFile, unsealed.Bytes())
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+// copyToClipboard copies the secret to the clipboard, then waits for timeout
+// or an interrupt and clears it. The clipboard is only cleared if it still
+// holds the secret, so anything copied since isn't lost. A zero timeout leaves
+// the secret in the clipboard.
+func copyToClipboard(secret []byte, timeout time.Duration) error {
+	if err := clipboard.WriteAll(string(secret)); err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+
+	if timeout == 0 {
+		fmt.Fprintln(os.Stderr, "Copied to clipboard")
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Copied to clipboard, clearing it in %s or on Ctrl-C\n", timeout)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(timeout):
+	}
+
+	current, err := clipboard.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to clear clipboard: %w", err)
+	}
+	if current != string(secret) {
+		return nil
+	}
+
+	if err := clipboard.WriteAll(""); err != nil {
+		return fmt.Errorf("failed to clear clipboard: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Cleared clipboard")
+
+	return nil
+}
@@ -1,19 +1,90 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/alecthomas/kong"
 	"github.com/cedws/amnesia/pkg/amnesia/ageplugin"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 	"github.com/charmbracelet/x/term"
 )
 
 type cli struct {
-	Seal      sealCmd      `cmd:""`
-	Unseal    unsealCmd    `cmd:""`
-	Reseal    resealCmd    `cmd:""`
-	Open      openCmd      `cmd:""`
-	AgeKeygen ageKeygenCmd `cmd:""`
+	Seal           sealCmd           `cmd:""`
+	Generate       generateCmd       `cmd:""`
+	Unseal         unsealCmd         `cmd:""`
+	Reseal         resealCmd         `cmd:""`
+	Open           openCmd           `cmd:""`
+	AgeKeygen      ageKeygenCmd      `cmd:""`
+	AgeRecipient   ageRecipientCmd   `cmd:""`
+	AgeIdentity    ageIdentityCmd    `cmd:""`
+	AgeRekey       ageRekeyCmd       `cmd:""`
+	AgeKeyring     ageKeyringCmd     `cmd:""`
+	SSHKeygen      sshKeygenCmd      `cmd:"" name:"ssh-keygen"`
+	SSHAgent       sshAgentCmd       `cmd:"" name:"ssh-agent"`
+	Agent          agentCmd          `cmd:""`
+	Slots          slotsCmd          `cmd:""`
+	Trustee        trusteeCmd        `cmd:""`
+	Shares         sharesCmd         `cmd:""`
+	Ceremony       ceremonyCmd       `cmd:""`
+	Slip39         slip39Cmd         `cmd:"" name:"slip39"`
+	DerivePassword derivePasswordCmd `cmd:""`
+	DeriveKey      deriveKeyCmd      `cmd:""`
+}
+
+// readKeyfile reads the keyfile at path, or returns nil if path is empty
+func readKeyfile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	keyfile, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keyfile: %w", err)
+	}
+
+	return keyfile, nil
+}
+
+// writeOutput writes data to path, or to stdout if path is empty
+func writeOutput(path string, data []byte) error {
+	if path != "" {
+		return writeFileAtomic(path, data)
+	}
+
+	_, err := os.Stdout.Write(data)
+	return err
+}
+
+// writeFileAtomic replaces the file at path with data, so an interrupted write
+// never leaves it truncated. Outputs such as an existing device or pipe are
+// written to directly.
+func writeFileAtomic(path string, data []byte) error {
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		return os.WriteFile(path, data, 0600)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
 }
 
 func haveStdin() bool {
@@ -21,6 +92,10 @@ func haveStdin() bool {
 }
 
 func Execute() {
+	if err := secmem.DisableCoreDumps(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to disable core dumps: %v\n", err)
+	}
+
 	if os.Args[0] == "age-plugin-amnesia" {
 		os.Exit(ageplugin.Main())
 	}
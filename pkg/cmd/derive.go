@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/derive"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+type derivePasswordCmd struct {
+	File        string        `help:"Sealed file holding the master secret." short:"f" required:"" type:"existingfile"`
+	Site        string        `help:"Site to derive the password for." short:"s" required:""`
+	Counter     int           `help:"Counter to increment when rotating the password. Defaults to the site profile, or 1."`
+	Length      int           `help:"Length of the password. Defaults to the site profile, or 24." short:"l"`
+	Charset     []string      `help:"Character classes for the password. Defaults to the site profile, or all classes." enum:"lower,upper,digits,symbols"`
+	Chars       string        `help:"Characters for the password, overriding --charset."`
+	Sites       string        `help:"Site profile file recording the parameters of each site." env:"AMNESIA_SITES" type:"path"`
+	Save        bool          `help:"Record the parameters used in the site profile file."`
+	Clip        bool          `help:"Copy the password to the clipboard instead of printing it."`
+	ClipTimeout time.Duration `help:"How long to leave the password in the clipboard before clearing it, or 0 to leave it there." default:"45s"`
+	unlockFlags `embed:""`
+}
+
+func (d *derivePasswordCmd) Help() string {
+	return `Derive a site's password from a sealed master secret.
+
+The password is derived with HKDF-SHA256 from the master secret, the site name, the counter, the length and the charset, so the same answers always give the same password and nothing per-site needs to be stored. The site profile file records the parameters but no secrets.
+
+Examples:
+  amnesia derive-password -f master.json --site example.com
+  amnesia derive-password -f master.json --site example.com --counter 2 --length 16 --charset lower,digits
+  amnesia derive-password -f master.json --site example.com --sites sites.json --save --clip`
+}
+
+func (d *derivePasswordCmd) AfterApply() error {
+	if d.Save && d.Sites == "" {
+		return fmt.Errorf("--save requires a site profile file")
+	}
+
+	return nil
+}
+
+// site resolves the site's parameters from the flags, falling back to the
+// site profile file and then the defaults
+func (d *derivePasswordCmd) site(sites *derive.Sites) derive.Site {
+	site, ok := sites.Get(d.Site)
+	if !ok {
+		site = derive.Site{Counter: derive.DefaultCounter, Length: derive.DefaultLength}
+	}
+
+	if d.Counter != 0 {
+		site.Counter = d.Counter
+	}
+	if d.Length != 0 {
+		site.Length = d.Length
+	}
+	if len(d.Charset) > 0 {
+		site.Charset = charsetFromClasses(d.Charset)
+	}
+	if d.Chars != "" {
+		site.Charset = d.Chars
+	}
+
+	return site
+}
+
+func (d *derivePasswordCmd) Run(ctx *kong.Context) error {
+	sites, err := readSites(d.Sites)
+	if err != nil {
+		return err
+	}
+
+	site := d.site(sites)
+
+	sealed, err := os.ReadFile(d.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := d.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	master, err := interactive.Unseal(context.Background(), sealed, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to unseal master secret: %w", err)
+	}
+	defer master.Destroy()
+
+	password, err := derive.Password(master.Bytes(), d.Site, site)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(password)
+
+	if d.Save {
+		sites.Set(d.Site, site)
+
+		encoded, err := sites.Encode()
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(d.Sites, encoded); err != nil {
+			return err
+		}
+	}
+
+	if d.Clip {
+		return copyToClipboard(password, d.ClipTimeout)
+	}
+
+	return writeOutput("", append(password, '\n'))
+}
+
+// readSites reads the site profile file, which may not exist yet
+func readSites(path string) (*derive.Sites, error) {
+	if path == "" {
+		return &derive.Sites{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &derive.Sites{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return derive.ParseSites(data)
+}
+
+type deriveKeyCmd struct {
+	File        string         `help:"Sealed file holding the root secret." short:"f" required:"" type:"existingfile"`
+	Purpose     derive.Purpose `help:"Type of key to derive." enum:"age,ssh-ed25519,ed25519,x25519,raw" required:""`
+	Label       string         `help:"Label distinguishing keys of the same type, also used as the SSH key comment." short:"l"`
+	Private     bool           `help:"Output the private key as well as the public key."`
+	OutputFile  string         `help:"File to write the private key to, implies --private." short:"o"`
+	unlockFlags `embed:""`
+}
+
+func (d *deriveKeyCmd) Help() string {
+	return `Derive a key from a sealed root secret.
+
+Keys are derived with HKDF-SHA256 from the root secret, the purpose and the label, so one sealed secret can stand in for many keys and a lost key can be regenerated from the same answers. The public key is printed, and the private key is written out with --private or -o.
+
+Examples:
+  amnesia derive-key -f root.json --purpose age --label work
+  amnesia derive-key -f root.json --purpose age --label work -o work.txt
+  amnesia derive-key -f root.json --purpose ssh-ed25519 --label laptop -o ~/.ssh/id_ed25519
+  amnesia derive-key -f root.json --purpose raw --label backups --private`
+}
+
+func (d *deriveKeyCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(d.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := d.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	master, err := interactive.Unseal(context.Background(), sealed, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to unseal root secret: %w", err)
+	}
+	defer master.Destroy()
+
+	key, err := derive.DeriveKey(master.Bytes(), d.Purpose, d.Label)
+	if err != nil {
+		return err
+	}
+	defer key.Wipe()
+
+	if key.Public != "" {
+		fmt.Println(key.Public)
+	}
+
+	if d.OutputFile != "" || d.Private {
+		return writeOutput(d.OutputFile, key.Private)
+	}
+	if key.Public == "" {
+		fmt.Fprintln(os.Stderr, "raw keys have no public half, use --private to output the key")
+	}
+
+	return nil
+}
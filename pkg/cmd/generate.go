@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/generate"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+type generateCmd struct {
+	sealFlags   `embed:""`
+	Profile     generate.Profile `help:"Kind of secret to generate." enum:"password,passphrase,bytes,hex,base64,bip39" default:"password"`
+	BIP39       bool             `help:"Generate a BIP-39 seed mnemonic, short for --profile bip39." name:"bip39"`
+	Length      int              `help:"Characters in a password, or bytes for the bytes, hex and base64 profiles." default:"32"`
+	Charset     []string         `help:"Character classes for a password." enum:"lower,upper,digits,symbols" default:"lower,upper,digits,symbols"`
+	Chars       string           `help:"Characters for a password, overriding --charset."`
+	Words       int              `help:"Words in a passphrase or BIP-39 mnemonic. Defaults to 6 for a passphrase and 24 for a mnemonic."`
+	Separator   string           `help:"Separator between passphrase words." default:"-"`
+	Show        bool             `help:"Show the secret once after sealing so it can be memorised."`
+	Clip        bool             `help:"Copy the secret to the clipboard after sealing."`
+	ClipTimeout time.Duration    `help:"How long to leave the secret in the clipboard before clearing it, or 0 to leave it there." default:"45s"`
+}
+
+func (g *generateCmd) Help() string {
+	return `Generate a random secret and seal it.
+
+The secret is sealed straight away without passing through stdin, shell history or process listings. It's never shown unless --show or --clip is given, and --clip clears the clipboard again after --clip-timeout. A BIP-39 seed is never shown, so neither can be used with --bip39.
+
+Passphrases are drawn from the BIP-39 English wordlist of 2048 words, giving 11 bits per word.
+
+Examples:
+  amnesia generate -o sealed.json
+  amnesia generate --length 20 --charset lower,digits --show -o sealed.json
+  amnesia generate --profile passphrase --words 8 --show -o sealed.json
+  amnesia generate --profile hex --length 32 --clip -o sealed.json
+  amnesia generate --bip39 -o seed.json`
+}
+
+func (g *generateCmd) AfterApply() error {
+	if g.BIP39 {
+		g.Profile = generate.ProfileBIP39
+	}
+
+	if g.Words == 0 {
+		switch g.Profile {
+		case generate.ProfilePassphrase:
+			g.Words = 6
+		case generate.ProfileBIP39:
+			g.Words = 24
+		}
+	}
+
+	if (g.Show || g.Clip) && g.Profile == generate.ProfileBIP39 {
+		return fmt.Errorf("a BIP-39 seed is never shown, so --show and --clip can't be used with it")
+	}
+	if g.Show && g.Profile == generate.ProfileBytes {
+		return fmt.Errorf("raw bytes can't be shown, use the hex or base64 profile")
+	}
+
+	return nil
+}
+
+func (g *generateCmd) spec() generate.Spec {
+	charset := g.Chars
+	if charset == "" {
+		charset = charsetFromClasses(g.Charset)
+	}
+
+	return generate.Spec{
+		Profile:   g.Profile,
+		Length:    g.Length,
+		Charset:   charset,
+		Words:     g.Words,
+		Separator: g.Separator,
+	}
+}
+
+// charsetFromClasses joins the characters of the named character classes
+func charsetFromClasses(classes []string) string {
+	chars := map[string]string{
+		"lower":   generate.Lower,
+		"upper":   generate.Upper,
+		"digits":  generate.Digits,
+		"symbols": generate.Symbols,
+	}
+
+	var b strings.Builder
+	for _, class := range classes {
+		b.WriteString(chars[class])
+	}
+
+	return b.String()
+}
+
+func (g *generateCmd) Run(ctx *kong.Context) error {
+	spec := g.spec()
+
+	secret, err := generate.Generate(rand.Reader, spec)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(secret)
+
+	var opts []interactive.Option
+	if g.Profile == generate.ProfileBIP39 {
+		opts = append(opts, interactive.WithBIP39())
+	}
+
+	if err := g.seal(secret, opts...); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Sealed a %s with %.0f bits of entropy\n", g.Profile, generate.Bits(spec))
+
+	if g.Show {
+		if err := interactive.ShowSecret(context.Background(), secret); err != nil {
+			return err
+		}
+	}
+
+	if g.Clip {
+		return copyToClipboard(secret, g.ClipTimeout)
+	}
+
+	return nil
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/alecthomas/kong"
 	"github.com/cedws/amnesia/pkg/amnesia"
 	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 	"github.com/gofrs/flock"
 )
 
 type openCmd struct {
-	File       string `help:"File to read sealed secret from." required:"true" short:"f"`
-	SecretFile string `help:"File to write secret to and reseal later."  required:"true" short:"o"`
+	File        string `help:"File to read sealed secret from." required:"true" short:"f"`
+	SecretFile  string `help:"File to write secret to and reseal later."  required:"true" short:"o"`
+	unlockFlags `embed:""`
 }
 
 func (o *openCmd) Help() string {
@@ -44,17 +46,25 @@ func (o *openCmd) Run(ctx *kong.Context) error {
 		return err
 	}
 
-	key, err := interactive.DecryptKey(context.Background(), sealed)
+	opts, wipe, err := o.interactiveOpts()
 	if err != nil {
 		return err
 	}
+	defer wipe()
 
-	secret, err := amnesia.UnsealWithKey(sealed, key)
+	key, err := interactive.DecryptKey(context.Background(), sealed, opts...)
 	if err != nil {
 		return err
 	}
+	defer key.Destroy()
 
-	if err := os.WriteFile(o.SecretFile, secret, 0600); err != nil {
+	secret, err := amnesia.UnsealWithKey(sealed, key.Bytes())
+	if err != nil {
+		return err
+	}
+	defer secret.Destroy()
+
+	if err := os.WriteFile(o.SecretFile, secret.Bytes(), 0600); err != nil {
 		return err
 	}
 	defer os.Remove(o.SecretFile)
@@ -65,7 +75,7 @@ func (o *openCmd) Run(ctx *kong.Context) error {
 	fmt.Println("Awaiting ^C to reseal...")
 	<-signalCh
 
-	if err := o.reseal(sealed, key); err != nil {
+	if err := o.reseal(sealed, key.Bytes()); err != nil {
 		return err
 	}
 
@@ -104,6 +114,7 @@ func (o *openCmd) reseal(sealed, key []byte) error {
 	if err != nil {
 		return err
 	}
+	defer secmem.Wipe(secret)
 
 	newSealed, err := amnesia.ResealWithKey(sealed, secret, key)
 	if err != nil {
@@ -8,12 +8,14 @@ import (
 
 	"github.com/alecthomas/kong"
 	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 	"github.com/charmbracelet/x/term"
 )
 
 type resealCmd struct {
-	File       string `help:"File to reseal secret from." short:"f"`
-	OutputFile string `help:"File to write resealed secret to." short:"o"`
+	File        string `help:"File to reseal secret from." short:"f"`
+	OutputFile  string `help:"File to write resealed secret to." short:"o"`
+	unlockFlags `embed:""`
 }
 
 func (r *resealCmd) Help() string {
@@ -48,8 +50,15 @@ func (r *resealCmd) Run(ctx *kong.Context) error {
 	if err != nil {
 		return err
 	}
+	defer secmem.Wipe(newSecret)
 
-	resealed, err := interactive.Reseal(context.Background(), sealed, newSecret)
+	opts, wipe, err := r.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	resealed, err := interactive.Reseal(context.Background(), sealed, newSecret, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to reseal secret: %w", err)
 	}
@@ -7,12 +7,48 @@ import (
 	"os"
 
 	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/agecrypt"
 	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
 )
 
+// sealFlags are shared by the commands that seal a new secret
+type sealFlags struct {
+	OutputFile string   `help:"File to write sealed secret to." short:"o"`
+	NoTest     bool     `help:"Don't prompt for test questions." short:"t"`
+	Keyfile    string   `help:"Require the contents of this file in addition to answers." short:"k" type:"existingfile"`
+	Trustee    []string `help:"age recipient of a trusted person to give a share to, in addition to the questions."`
+}
+
+// seal seals the secret through the question flow and writes it out
+func (s *sealFlags) seal(secret []byte, opts ...interactive.Option) error {
+	keyfile, err := readKeyfile(s.Keyfile)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(keyfile)
+
+	opts = append(opts,
+		interactive.WithKeyfile(keyfile),
+		interactive.WithTrustees(s.Trustee...),
+	)
+	if !s.NoTest {
+		opts = append(opts, interactive.WithTestQuestions())
+	}
+
+	sealed, err := interactive.Seal(context.Background(), secret, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to seal secret: %w", err)
+	}
+
+	return writeOutput(s.OutputFile, sealed)
+}
+
 type sealCmd struct {
-	OutputFile string `help:"File to write sealed secret to." short:"o"`
-	NoTest     bool   `help:"Don't prompt for test questions." short:"t"`
+	sealFlags `embed:""`
+	BIP39     bool     `help:"Validate the secret as a BIP-39 mnemonic and seal its entropy." name:"bip39"`
+	FromAge   bool     `help:"Decrypt the age encrypted secret passed via stdin before sealing it."`
+	Identity  []string `help:"age identity file to decrypt the secret with --from-age." short:"i" type:"existingfile"`
 }
 
 func (s *sealCmd) Help() string {
@@ -23,25 +59,22 @@ This command reads sensitive data from stdin and encrypts it using a set of ques
 Examples:
   echo "my secret password" | amnesia seal
   cat ~/.ssh/id_rsa | amnesia seal -o sealed.json
-  amnesia seal -o sealed.json < large-file.txt`
+  amnesia seal -o sealed.json < large-file.txt
+  amnesia seal -o sealed.json -k /media/usb/keyfile < secret.txt
+  amnesia seal -o sealed.json --trustee age1... --trustee age1... < secret.txt
+  amnesia seal -o seed.json --bip39 < seed-phrase.txt
+  amnesia seal -o sealed.json --from-age -i key.txt < secret.age`
 }
 
 func (s *sealCmd) AfterApply() error {
 	if !haveStdin() {
 		return fmt.Errorf("no data passed to stdin")
 	}
-
-	return nil
-}
-
-func (s *sealCmd) interactiveOpts() []interactive.Option {
-	var opts []interactive.Option
-
-	if !s.NoTest {
-		opts = append(opts, interactive.WithTestQuestions())
+	if s.FromAge && len(s.Identity) == 0 {
+		return fmt.Errorf("--from-age requires an identity file")
 	}
 
-	return opts
+	return nil
 }
 
 func (s *sealCmd) Run(ctx *kong.Context) error {
@@ -49,23 +82,24 @@ func (s *sealCmd) Run(ctx *kong.Context) error {
 	if err != nil {
 		return err
 	}
+	defer secmem.Wipe(data)
 
-	sealed, err := interactive.Seal(context.Background(), data, s.interactiveOpts()...)
-	if err != nil {
-		return fmt.Errorf("failed to seal secret: %w", err)
-	}
-
-	if s.OutputFile != "" {
-		if err := os.WriteFile(s.OutputFile, sealed, 0600); err != nil {
+	if s.FromAge {
+		identities, err := readIdentities(s.Identity)
+		if err != nil {
 			return err
 		}
 
-		return nil
+		if data, err = agecrypt.Decrypt(data, identities...); err != nil {
+			return fmt.Errorf("failed to decrypt secret: %w", err)
+		}
+		defer secmem.Wipe(data)
 	}
 
-	if _, err := os.Stdout.Write(sealed); err != nil {
-		return err
+	var opts []interactive.Option
+	if s.BIP39 {
+		opts = append(opts, interactive.WithBIP39())
 	}
 
-	return nil
+	return s.seal(data, opts...)
 }
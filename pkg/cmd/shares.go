@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia"
+)
+
+type sharesCmd struct {
+	Export sharesExportCmd `cmd:""`
+	Import sharesImportCmd `cmd:""`
+}
+
+func (s *sharesCmd) Help() string {
+	return `Export and import individual shares as standalone files.
+
+A share file holds one share, still encrypted under its question or to its trustee, along with the question text, a fingerprint of the sealed file it belongs to and a checksum. Share files can be stored apart from the sealed file, such as on paper or a USB stick held by a friend, and merged back when needed.`
+}
+
+type sharesExportCmd struct {
+	File       string `help:"Sealed file to export a share from." short:"f" required:"" type:"existingfile"`
+	OutputFile string `help:"File to write the share file to." short:"o"`
+	ID         int    `help:"ID of the share to export." required:""`
+	Remove     bool   `help:"Remove the share from the sealed file, which is updated in place."`
+}
+
+func (e *sharesExportCmd) Help() string {
+	return `Export a share to a share file.
+
+With --remove, the share is taken out of the sealed file so it has to be imported again before it can be used. The question stays in the sealed file.
+
+Examples:
+  amnesia shares export -f sealed.json --id 2 -o share-2.json
+  amnesia shares export -f sealed.json --id 2 -o /media/usb/share-2.json --remove`
+}
+
+func (e *sharesExportCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(e.File)
+	if err != nil {
+		return err
+	}
+
+	exported, updated, err := amnesia.ExportShare(sealed, e.ID, e.Remove)
+	if err != nil {
+		return fmt.Errorf("failed to export share: %w", err)
+	}
+
+	// Write the share out before removing it from the sealed file
+	if err := writeOutput(e.OutputFile, exported); err != nil {
+		return err
+	}
+
+	if updated != nil {
+		return writeFileAtomic(e.File, updated)
+	}
+
+	return nil
+}
+
+type sharesImportCmd struct {
+	File       string   `help:"Sealed file to import shares into." short:"f" required:"" type:"existingfile"`
+	OutputFile string   `help:"File to write the updated sealed file to." short:"o"`
+	Shares     []string `arg:"" help:"Share files to import." type:"existingfile"`
+}
+
+func (i *sharesImportCmd) Help() string {
+	return `Import share files back into the sealed file they were exported from.
+
+Examples:
+  amnesia shares import -f sealed.json -o sealed.json /media/usb/share-2.json`
+}
+
+func (i *sharesImportCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(i.File)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range i.Shares {
+		shareFile, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		sealed, err = amnesia.ImportShare(sealed, shareFile)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", path, err)
+		}
+	}
+
+	return writeOutput(i.OutputFile, sealed)
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"github.com/cedws/amnesia/pkg/amnesia/slip39"
+)
+
+type slip39Cmd struct {
+	Export slip39ExportCmd `cmd:""`
+	Import slip39ImportCmd `cmd:""`
+}
+
+func (s *slip39Cmd) Help() string {
+	return `Export and import SLIP-39 Shamir mnemonics.
+
+SLIP-39 mnemonics can be written on paper and recovered with hardware wallets and other SLIP-39 tools.`
+}
+
+type slip39ExportCmd struct {
+	File              string   `help:"Sealed file to export." short:"f" required:"" type:"existingfile"`
+	OutputFile        string   `help:"File to write the mnemonics to." short:"o"`
+	Secret            bool     `help:"Export the secret itself rather than the key that decrypts the sealed file."`
+	Group             []string `help:"Group of shares as threshold/count, repeat for several groups." default:"1/1"`
+	GroupThreshold    int      `help:"Number of groups required to recover." default:"1"`
+	IterationExponent int      `help:"Passphrase key derivation cost, 10000×2^e PBKDF2 iterations." default:"1"`
+	Passphrase        bool     `help:"Prompt for a passphrase that will be required to recover." short:"p"`
+	unlockFlags       `embed:""`
+
+	groups []slip39.Group
+}
+
+func (e *slip39ExportCmd) Help() string {
+	return `Export a sealed file as SLIP-39 mnemonics.
+
+By default the key that decrypts the sealed file is exported, so the mnemonics together with the sealed file recover the secret without any answers. With --secret, the secret itself is exported and the mnemonics alone recover it. The secret must be an even number of bytes, at least 16.
+
+Examples:
+  amnesia slip39 export -f sealed.json -o mnemonics.txt
+  amnesia slip39 export -f sealed.json --group 2/3 -o mnemonics.txt
+  amnesia slip39 export -f sealed.json --secret --group-threshold 2 --group 2/3 --group 3/5 --group 1/1`
+}
+
+func (e *slip39ExportCmd) AfterApply() error {
+	for _, group := range e.Group {
+		threshold, count, ok := strings.Cut(group, "/")
+		if !ok {
+			return fmt.Errorf("invalid group %q, expected threshold/count", group)
+		}
+
+		t, err := strconv.Atoi(threshold)
+		if err != nil {
+			return fmt.Errorf("invalid group %q: %w", group, err)
+		}
+		c, err := strconv.Atoi(count)
+		if err != nil {
+			return fmt.Errorf("invalid group %q: %w", group, err)
+		}
+
+		e.groups = append(e.groups, slip39.Group{Threshold: t, Count: c})
+	}
+
+	return nil
+}
+
+func (e *slip39ExportCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(e.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := e.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	spec := interactive.MnemonicSpec{
+		Secret:            e.Secret,
+		GroupThreshold:    e.GroupThreshold,
+		Groups:            e.groups,
+		IterationExponent: e.IterationExponent,
+		Passphrase:        e.Passphrase,
+	}
+
+	groups, err := interactive.ExportMnemonics(context.Background(), sealed, spec, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to export mnemonics: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# %d of %d groups required\n", e.GroupThreshold, len(groups))
+	for i, mnemonics := range groups {
+		fmt.Fprintf(&buf, "\n# Group %d, %d of %d shares required\n", i+1, e.groups[i].Threshold, len(mnemonics))
+		for _, mnemonic := range mnemonics {
+			fmt.Fprintln(&buf, mnemonic)
+		}
+	}
+	defer secmem.Wipe(buf.Bytes())
+
+	return writeOutput(e.OutputFile, buf.Bytes())
+}
+
+type slip39ImportCmd struct {
+	File       string   `help:"Sealed file to unseal with mnemonics of its key." short:"f" type:"existingfile"`
+	OutputFile string   `help:"File to write the secret, or the new sealed file, to." short:"o"`
+	Seal       bool     `help:"Seal the recovered secret with new questions."`
+	Passphrase bool     `help:"Prompt for the passphrase the mnemonics were exported with." short:"p"`
+	NoTest     bool     `help:"Don't prompt for test questions when sealing." short:"t"`
+	Mnemonics  []string `arg:"" optional:"" help:"Files of mnemonics, one per line. Prompted for if not given." type:"existingfile"`
+}
+
+func (i *slip39ImportCmd) Help() string {
+	return `Recover a secret from SLIP-39 mnemonics.
+
+Mnemonics of a sealed file's key are given along with the sealed file. Mnemonics of a secret recover it directly. Lines starting with # are ignored. With --seal, the recovered secret is sealed with new questions, rebuilding a sealed file.
+
+Examples:
+  amnesia slip39 import -f sealed.json mnemonics.txt
+  amnesia slip39 import -o secret.txt alice.txt bob.txt
+  amnesia slip39 import --seal -o sealed.json mnemonics.txt`
+}
+
+func (i *slip39ImportCmd) Run(ctx *kong.Context) error {
+	var mnemonics []string
+
+	for _, path := range i.Mnemonics {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		mnemonics = append(mnemonics, slip39.ParseMnemonics(string(data))...)
+		secmem.Wipe(data)
+	}
+
+	secret, err := interactive.ImportMnemonics(context.Background(), mnemonics, i.Passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to import mnemonics: %w", err)
+	}
+	defer secret.Destroy()
+
+	if i.File != "" {
+		sealed, err := os.ReadFile(i.File)
+		if err != nil {
+			return err
+		}
+
+		key := secret
+		if secret, err = amnesia.UnsealWithKey(sealed, key.Bytes()); err != nil {
+			return fmt.Errorf("failed to unseal secret (wrong mnemonics or passphrase?): %w", err)
+		}
+		defer secret.Destroy()
+	}
+
+	if !i.Seal {
+		return writeOutput(i.OutputFile, secret.Bytes())
+	}
+
+	var opts []interactive.Option
+	if !i.NoTest {
+		opts = append(opts, interactive.WithTestQuestions())
+	}
+
+	sealed, err := interactive.Seal(context.Background(), secret.Bytes(), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to seal secret: %w", err)
+	}
+
+	return writeOutput(i.OutputFile, sealed)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+type slotsCmd struct {
+	List   slotsListCmd   `cmd:""`
+	Add    slotsAddCmd    `cmd:""`
+	Remove slotsRemoveCmd `cmd:""`
+}
+
+func (s *slotsCmd) Help() string {
+	return `Manage the key slots of a sealed file.
+
+Each slot independently unlocks the secret, so it can be recovered with any one of several question sets, a passphrase, an age identity or a keyfile. Adding or removing a slot requires unlocking an existing slot first. Files sealed before slots existed are upgraded when a slot is first added.`
+}
+
+type slotsListCmd struct {
+	File string `help:"Sealed file to list slots of." short:"f" required:"" type:"existingfile"`
+}
+
+func (l *slotsListCmd) Help() string {
+	return `List the slots of a sealed file.
+
+Examples:
+  amnesia slots list -f sealed.json`
+}
+
+func (l *slotsListCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(l.File)
+	if err != nil {
+		return err
+	}
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	if err != nil {
+		return err
+	}
+
+	if sealedSecret.Version != amnesia.FormatV3 {
+		fmt.Printf("version %s file with a single share set of %d questions\n", sealedSecret.Version, len(sealedSecret.Shares))
+		return nil
+	}
+
+	for _, slot := range sealedSecret.Slots {
+		fmt.Println(interactive.DescribeSlot(&slot))
+	}
+
+	return nil
+}
+
+type slotsAddCmd struct {
+	File        string           `help:"Sealed file to add a slot to." short:"f" required:"" type:"existingfile"`
+	OutputFile  string           `help:"File to write the updated sealed file to." short:"o"`
+	Type        amnesia.SlotType `help:"Type of slot to add. Prompted for if not given." enum:"questions,passphrase,age,keyfile," default:""`
+	Label       string           `help:"Label to describe the slot." short:"l"`
+	Recipient   string           `help:"age recipient for an age slot." short:"r"`
+	NewKeyfile  string           `help:"Keyfile for a keyfile slot, or to require in addition to answers for a questions slot." type:"existingfile"`
+	unlockFlags `embed:""`
+	NoTest      bool `help:"Don't prompt for test questions." short:"t"`
+}
+
+func (a *slotsAddCmd) Help() string {
+	return `Add a slot to a sealed file.
+
+Examples:
+  amnesia slots add -f sealed.json -o sealed.json --type passphrase --label lawyer
+  amnesia slots add -f sealed.json -o sealed.json --type questions --label partner
+  amnesia slots add -f sealed.json -o sealed.json --type age -r age1...
+  amnesia slots add -f sealed.json -o sealed.json --type keyfile --new-keyfile /media/usb/keyfile`
+}
+
+func (a *slotsAddCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(a.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := a.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	newKeyfile, err := readKeyfile(a.NewKeyfile)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(newKeyfile)
+
+	spec := amnesia.SlotSpec{
+		Type:      a.Type,
+		Label:     a.Label,
+		Recipient: a.Recipient,
+		Keyfile:   newKeyfile,
+	}
+
+	if !a.NoTest {
+		opts = append(opts, interactive.WithTestQuestions())
+	}
+
+	updated, err := interactive.AddSlot(context.Background(), sealed, spec, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to add slot: %w", err)
+	}
+
+	return writeOutput(a.OutputFile, updated)
+}
+
+type slotsRemoveCmd struct {
+	File        string `help:"Sealed file to remove a slot from." short:"f" required:"" type:"existingfile"`
+	OutputFile  string `help:"File to write the updated sealed file to." short:"o"`
+	ID          int    `arg:"" help:"ID of the slot to remove."`
+	unlockFlags `embed:""`
+}
+
+func (r *slotsRemoveCmd) Help() string {
+	return `Remove a slot from a sealed file.
+
+The key protecting the secret doesn't change, so copies of the file made before removing the slot can still be unlocked by it, and so can this file by anyone who recovered the key from them. Resealing keeps the same key too. If that matters, seal the secret into a new file with amnesia seal and destroy the old copies.
+
+Examples:
+  amnesia slots remove -f sealed.json -o sealed.json 1`
+}
+
+func (r *slotsRemoveCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(r.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := r.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	updated, err := interactive.RemoveSlot(context.Background(), sealed, r.ID, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to remove slot: %w", err)
+	}
+
+	return writeOutput(r.OutputFile, updated)
+}
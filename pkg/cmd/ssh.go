@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+	"github.com/cedws/amnesia/pkg/amnesia/sshagent"
+	"golang.org/x/crypto/ssh"
+)
+
+type sshKeygenCmd struct {
+	OutputFile string `help:"File to write the sealed key to." short:"o" name:"output" required:""`
+	Comment    string `help:"Comment for the public key." short:"C"`
+	NoTest     bool   `help:"Don't prompt for test questions." short:"t"`
+	Keyfile    string `help:"Require the contents of this file in addition to answers." short:"k" type:"existingfile"`
+}
+
+func (s *sshKeygenCmd) Help() string {
+	return `Generate a sealed ed25519 SSH key.
+
+The private key is sealed straight away and never written to disk unsealed. The authorized_keys line is printed, and the key can be used with amnesia ssh-agent.
+
+Examples:
+  amnesia ssh-keygen -o key.json
+  amnesia ssh-keygen -C me@laptop -o key.json >> ~/.ssh/authorized_keys`
+}
+
+func (s *sshKeygenCmd) Run(ctx *kong.Context) error {
+	keyfile, err := readKeyfile(s.Keyfile)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(keyfile)
+
+	opts := []interactive.Option{interactive.WithKeyfile(keyfile)}
+	if !s.NoTest {
+		opts = append(opts, interactive.WithTestQuestions())
+	}
+
+	key, err := sshagent.GenerateKey(context.Background(), s.Comment, opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := writeOutput(s.OutputFile, key.Sealed()); err != nil {
+		return err
+	}
+
+	fmt.Println(key.AuthorizedKey())
+
+	return nil
+}
+
+type sshAgentCmd struct {
+	File        string        `help:"Sealed SSH key to serve." short:"f" required:"" type:"existingfile"`
+	Socket      string        `help:"Path of the agent socket. Defaults to a new temporary directory." short:"a" type:"path"`
+	Lifetime    time.Duration `help:"How long to serve the key for before exiting, or 0 for no limit." short:"t" default:"1h"`
+	Confirm     bool          `help:"Ask for confirmation before each use of the key." short:"c"`
+	unlockFlags `embed:""`
+}
+
+func (s *sshAgentCmd) Help() string {
+	return `Serve a sealed SSH key over the ssh-agent protocol.
+
+The key is unsealed once and held in memory until the lifetime expires or the agent is interrupted. The SSH_AUTH_SOCK variable to use is printed in the same format as ssh-agent.
+
+Examples:
+  amnesia ssh-agent -f key.json
+  amnesia ssh-agent -f key.json --lifetime 15m --confirm
+  amnesia ssh-agent -f key.json -a ~/.ssh/amnesia.sock`
+}
+
+func (s *sshAgentCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(s.File)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := s.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	secret, err := interactive.Unseal(context.Background(), sealed, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to unseal key: %w", err)
+	}
+	defer secret.Destroy()
+
+	var agentOpts []sshagent.AgentOption
+	if s.Confirm {
+		agentOpts = append(agentOpts, sshagent.WithConfirm(func(comment string, public ssh.PublicKey) (bool, error) {
+			return interactive.ConfirmSignature(context.Background(), comment, ssh.FingerprintSHA256(public))
+		}))
+	}
+
+	agent, err := sshagent.NewAgent(secret.Bytes(), s.File, agentOpts...)
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+	secret.Destroy()
+
+	listener, cleanup, err := listenUnix(s.Socket, "amnesia-ssh-")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	serveCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if s.Lifetime > 0 {
+		serveCtx, cancel = context.WithTimeout(serveCtx, s.Lifetime)
+		defer cancel()
+	}
+
+	fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", listener.Addr())
+	fmt.Fprintf(os.Stderr, "Serving %s, interrupt to stop\n", ssh.FingerprintSHA256(agent.PublicKey()))
+
+	if err := agent.Serve(serveCtx, listener); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Agent stopped, key removed from memory")
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia"
+)
+
+type trusteeCmd struct {
+	Decrypt trusteeDecryptCmd `cmd:""`
+}
+
+func (t *trusteeCmd) Help() string {
+	return `Commands for trustees holding a share of a sealed secret.`
+}
+
+type trusteeDecryptCmd struct {
+	File       string   `help:"Sealed file containing the trustee's share." short:"f" required:"" type:"existingfile"`
+	OutputFile string   `help:"File to write the decrypted share to." short:"o"`
+	Identity   []string `help:"age identity file of the trustee." short:"i" required:"" type:"existingfile"`
+}
+
+func (d *trusteeDecryptCmd) Help() string {
+	return `Decrypt a trustee's share of a sealed secret.
+
+The decrypted share can be given back to the owner, who passes it to unseal with --trustee-share in place of an answer. A single share isn't enough to unseal the secret.
+
+Examples:
+  amnesia trustee decrypt -f sealed.json -i key.txt -o share.json`
+}
+
+func (d *trusteeDecryptCmd) Run(ctx *kong.Context) error {
+	sealed, err := os.ReadFile(d.File)
+	if err != nil {
+		return err
+	}
+
+	sealedSecret, err := amnesia.Decode(sealed)
+	if err != nil {
+		return err
+	}
+
+	identities, err := readIdentities(d.Identity)
+	if err != nil {
+		return err
+	}
+
+	share, err := amnesia.DecryptTrusteeShare(sealedSecret, identities...)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt share: %w", err)
+	}
+	defer share.Wipe()
+
+	encoded, err := share.Encode()
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(d.OutputFile, encoded)
+}
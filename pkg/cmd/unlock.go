@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"filippo.io/age"
+	"github.com/cedws/amnesia/pkg/amnesia"
+	"github.com/cedws/amnesia/pkg/amnesia/agecrypt"
+	"github.com/cedws/amnesia/pkg/amnesia/agent"
+	"github.com/cedws/amnesia/pkg/amnesia/interactive"
+	"github.com/cedws/amnesia/pkg/amnesia/secmem"
+)
+
+// unlockFlags are credentials that can be given up front instead of being
+// prompted for when unsealing
+type unlockFlags struct {
+	Keyfile      string   `help:"Keyfile to unseal with, if required. Prompted for if not given." short:"k" type:"existingfile"`
+	Identity     []string `help:"age identity file for age slots and trustee shares." short:"i" type:"existingfile"`
+	TrusteeShare []string `help:"Trustee's decrypted share file, used in place of an answer." type:"existingfile"`
+}
+
+// interactiveOpts reads the credentials. The returned func wipes them.
+func (u *unlockFlags) interactiveOpts() ([]interactive.Option, func(), error) {
+	var wipe []func()
+
+	wipeAll := func() {
+		for _, fn := range wipe {
+			fn()
+		}
+	}
+
+	keyfile, err := readKeyfile(u.Keyfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	wipe = append(wipe, func() { secmem.Wipe(keyfile) })
+
+	opts := []interactive.Option{interactive.WithKeyfile(keyfile)}
+
+	if client := agent.FromEnv(); client != nil {
+		opts = append(opts, interactive.WithAgent(client))
+	}
+
+	if len(u.Identity) > 0 {
+		identities, err := readIdentities(u.Identity)
+		if err != nil {
+			wipeAll()
+			return nil, nil, err
+		}
+
+		opts = append(opts, interactive.WithIdentities(identities...))
+	}
+
+	if len(u.TrusteeShare) > 0 {
+		var shares []*amnesia.TrusteeShare
+
+		for _, path := range u.TrusteeShare {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				wipeAll()
+				return nil, nil, fmt.Errorf("error reading trustee share: %w", err)
+			}
+
+			share, err := amnesia.ParseTrusteeShare(data)
+			secmem.Wipe(data)
+			if err != nil {
+				wipeAll()
+				return nil, nil, err
+			}
+
+			shares = append(shares, share)
+			wipe = append(wipe, share.Wipe)
+		}
+
+		opts = append(opts, interactive.WithTrusteeShares(shares...))
+	}
+
+	return opts, wipeAll, nil
+}
+
+func readIdentities(paths []string) ([]age.Identity, error) {
+	var identities []age.Identity
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading identity file: %w", err)
+		}
+
+		parsed, err := agecrypt.ParseIdentities(path, data, agecrypt.TerminalUI())
+		secmem.Wipe(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing identity file %s: %w", path, err)
+		}
+
+		identities = append(identities, parsed...)
+	}
+
+	return identities, nil
+}
@@ -6,14 +6,20 @@ import (
 	"io"
 	"os"
 
+	"filippo.io/age"
 	"github.com/alecthomas/kong"
+	"github.com/cedws/amnesia/pkg/amnesia/agecrypt"
 	"github.com/cedws/amnesia/pkg/amnesia/interactive"
 	"github.com/charmbracelet/x/term"
 )
 
 type unsealCmd struct {
-	File       string `help:"File to unseal secret from." short:"f"`
-	OutputFile string `help:"File to write unsealed secret to." short:"o"`
+	File        string   `help:"File to unseal secret from." short:"f"`
+	OutputFile  string   `help:"File to write unsealed secret to." short:"o"`
+	ToAge       []string `help:"age recipient to encrypt the unsealed secret to instead of writing it out." name:"to-age"`
+	ToAgeFile   []string `help:"File of age recipients to encrypt the unsealed secret to." name:"to-age-file" type:"existingfile"`
+	Armor       bool     `help:"PEM encode the age encrypted output." short:"a"`
+	unlockFlags `embed:""`
 }
 
 func (u *unsealCmd) Help() string {
@@ -25,13 +31,25 @@ Examples:
   amnesia unseal < sealed.json
   amnesia unseal -f sealed.json
   amnesia unseal -f sealed.json -o recovered-secret.txt
-  cat sealed.json | amnesia unseal -o original-file.txt`
+  amnesia unseal -f sealed.json -k /media/usb/keyfile
+  cat sealed.json | amnesia unseal -o original-file.txt
+
+With --to-age or --to-age-file, the secret is encrypted to the age recipients before it's written, so it never leaves the process unencrypted.
+
+  amnesia unseal -f sealed.json --to-age age1... -o secret.age
+  amnesia unseal -f sealed.json --to-age-file recipients.txt --armor`
 }
 
 func (u *unsealCmd) AfterApply() error {
 	if term.IsTerminal(uintptr(os.Stdin.Fd())) && u.File == "" {
 		return fmt.Errorf("file is required when not reading from stdin")
 	}
+	if u.Armor && len(u.ToAge) == 0 && len(u.ToAgeFile) == 0 {
+		return fmt.Errorf("--armor requires --to-age or --to-age-file")
+	}
+	if (len(u.ToAge) > 0 || len(u.ToAgeFile) > 0) && !u.Armor && u.OutputFile == "" && term.IsTerminal(uintptr(os.Stdout.Fd())) {
+		return fmt.Errorf("refusing to write binary age output to a terminal, use --armor or -o")
+	}
 
 	return nil
 }
@@ -42,20 +60,41 @@ func (u *unsealCmd) Run(ctx *kong.Context) error {
 		return err
 	}
 
-	unsealed, err := interactive.Unseal(context.Background(), input)
+	recipients, err := readRecipients(u.ToAge, u.ToAgeFile)
+	if err != nil {
+		return err
+	}
+
+	opts, wipe, err := u.interactiveOpts()
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	unsealed, err := interactive.Unseal(context.Background(), input, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to unseal secret: %w", err)
 	}
+	defer unsealed.Destroy()
+
+	if len(recipients) > 0 {
+		encrypted, err := agecrypt.Encrypt(unsealed.Bytes(), recipients, u.Armor)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(u.OutputFile, encrypted)
+	}
 
 	if u.OutputFile != "" {
-		if err := os.WriteFile(u.OutputFile, unsealed, 0600); err != nil {
+		if err := os.WriteFile(u.OutputFile, unsealed.Bytes(), 0600); err != nil {
 			return err
 		}
 
 		return nil
 	}
 
-	if _, err := os.Stdout.Write(unsealed); err != nil {
+	if _, err := os.Stdout.Write(unsealed.Bytes()); err != nil {
 		return err
 	}
 
@@ -74,3 +113,36 @@ func readInput(cmd *unsealCmd) ([]byte, error) {
 
 	return buf, nil
 }
+
+// readRecipients parses age recipients given directly and in recipients files
+func readRecipients(recipients, paths []string) ([]age.Recipient, error) {
+	var (
+		parsed []age.Recipient
+		ui     = agecrypt.TerminalUI()
+	)
+
+	for _, recipient := range recipients {
+		r, err := agecrypt.ParseRecipient(recipient, ui)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", recipient, err)
+		}
+
+		parsed = append(parsed, r)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading recipients file: %w", err)
+		}
+
+		r, err := agecrypt.ParseRecipients(data, ui)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing recipients file %s: %w", path, err)
+		}
+
+		parsed = append(parsed, r...)
+	}
+
+	return parsed, nil
+}